@@ -32,6 +32,9 @@ func newGoCommand() *cobra.Command {
 	}
 	cmd.AddCommand(golang.NewGobuildCommand())
 	cmd.AddCommand(golang.NewGoInstallCommand())
+	cmd.AddCommand(golang.NewGoUninstallCommand())
+	cmd.AddCommand(golang.NewGoPackageCommand())
+	cmd.AddCommand(golang.NewGoSBOMCommand())
 	cmd.AddCommand(newGoModCommand())
 	cmd.AddCommand(golang.NewFormatSubcommand())
 	cmd.AddCommand(golang.NewGoUnittestCommand())
@@ -5,6 +5,8 @@ import (
 	"github.com/zoumo/golib/log"
 	"github.com/zoumo/golib/log/consolog"
 
+	"github.com/zoumo/make-rules/pkg/cli/cmd/env"
+	"github.com/zoumo/make-rules/pkg/cli/cmd/golang"
 	cliflag "github.com/zoumo/make-rules/pkg/cli/flag"
 	"github.com/zoumo/make-rules/version"
 )
@@ -26,7 +28,10 @@ func NewRootCommand() *cobra.Command {
 	// add subcommand
 	cmd.AddCommand(newGoCommand())
 	cmd.AddCommand(newContainerCommand())
-	cmd.AddCommand(version.NewCommand())
+	cmd.AddCommand(env.NewEnvCommand())
+	versionCmd := version.NewCommand()
+	versionCmd.AddCommand(golang.NewVersionInspectCommand())
+	cmd.AddCommand(versionCmd)
 
 	return cmd
 }
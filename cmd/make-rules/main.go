@@ -5,11 +5,17 @@ import (
 	"os"
 
 	"github.com/zoumo/make-rules/cmd/make-rules/app"
+	"github.com/zoumo/make-rules/pkg/runner"
 )
 
 func main() {
 	command := app.NewRootCommand()
-	if err := command.Execute(); err != nil {
+	err := command.Execute()
+	// write trace even if command failed, it tells which command is slow or failed
+	if terr := runner.WriteTrace(os.Stderr); terr != nil {
+		fmt.Printf("write trace error: %v\n", terr)
+	}
+	if err != nil {
 		fmt.Printf("run command error: %v\n", err)
 		os.Exit(1)
 	}
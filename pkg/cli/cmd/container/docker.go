@@ -1,8 +1,12 @@
 package container
 
 import (
+	"context"
 	"fmt"
 	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -11,6 +15,7 @@ import (
 	"github.com/zoumo/make-rules/pkg/cli/cmd/utils"
 	"github.com/zoumo/make-rules/pkg/cli/common"
 	"github.com/zoumo/make-rules/pkg/git"
+	goutil "github.com/zoumo/make-rules/pkg/golang"
 	"github.com/zoumo/make-rules/pkg/runner"
 )
 
@@ -22,12 +27,19 @@ var (
 type DockerBuildCommand struct {
 	*common.CommonOptions
 
-	dockerRunner *runner.Runner
+	dockerRunner runner.Executor
 
 	allTargets []string
 	targets    []string
 	git        *git.Repository
 	version    string
+	output     *goutil.OutputTemplate
+
+	// registries, imagePlatform and profile are set by flags, the ones set in
+	// command line are merged into config in Complete
+	registries    []string
+	imagePlatform string
+	profile       string
 }
 
 func NewContainerBuildCommand() *cobra.Command {
@@ -45,8 +57,10 @@ func (c *DockerBuildCommand) BindFlags(fs *pflag.FlagSet) {
 	// Call embedded CommonOptions.BindFlags first
 	c.CommonOptions.BindFlags(fs)
 
-	fs.StringSliceVar(&c.Config.Container.Registries, "registries", c.Config.Container.Registries, "docker image registries")
+	fs.StringSliceVar(&c.registries, "registries", c.registries, "docker image registries")
 	fs.StringVar(&c.version, "version", c.version, "go build target version")
+	fs.StringVar(&c.imagePlatform, "platform", c.imagePlatform, "platform of images, e.g. linux/arm64, defaults to linux on the host arch")
+	fs.StringVar(&c.profile, "profile", c.profile, "name of go build profile in go.build.profiles")
 }
 
 func (c *DockerBuildCommand) Complete(cmd *cobra.Command, args []string) error {
@@ -54,6 +68,16 @@ func (c *DockerBuildCommand) Complete(cmd *cobra.Command, args []string) error {
 	if err := c.CommonOptions.Complete(cmd, args); err != nil {
 		return err
 	}
+	// flags set in command line take precedence over the config file
+	if cmd.Flags().Changed("registries") {
+		c.Config.Container.Registries = c.registries
+	}
+	if cmd.Flags().Changed("platform") {
+		c.Config.Container.Platform = c.imagePlatform
+	}
+	if cmd.Flags().Changed("profile") {
+		c.Config.Go.Build.Profile = c.profile
+	}
 
 	// no targets, walk cmd/ dir to find targets
 	allTargets, err := utils.FindTargetsFrom(c.Workspace, "build", "Dockerfile")
@@ -69,6 +93,16 @@ func (c *DockerBuildCommand) Complete(cmd *cobra.Command, args []string) error {
 	} else {
 		c.git = r
 	}
+
+	// binaries are copied from the output of go build with the same profile
+	c.Config.Go.Build, err = c.Config.Go.Build.WithProfile(c.Config.Go.Build.Profile)
+	if err != nil {
+		return err
+	}
+	c.output, err = goutil.ParseOutputTemplate(c.Config.Go.Build.Output)
+	if err != nil {
+		return fmt.Errorf("invalid go build output template %q: %w", c.Config.Go.Build.Output, err)
+	}
 	return nil
 }
 
@@ -112,18 +146,79 @@ func (c *DockerBuildCommand) getDockerTag() string {
 	return version
 }
 
+// platform returns the platform of images
+func (c *DockerBuildCommand) platform() string {
+	if c.Config.Container.Platform != "" {
+		return c.Config.Container.Platform
+	}
+	return "linux/" + runtime.GOARCH
+}
+
+// binaryPath returns the go build output of target for the image platform
+// relative to workspace, it is passed to docker build as build arg
+// MAKE_RULES_BINARY, so Dockerfile can copy binary from the same path go
+// build writes to.
+func (c *DockerBuildCommand) binaryPath(target string) (string, error) {
+	version := utils.GitVersion(c.Logger, c.git, c.version)
+	output, err := c.output.Binary(c.Workspace, c.platform(), path.Base(target), version, c.Config.Go.Build.Profile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(c.Workspace, output)
+}
+
+// imageTag returns the local image tag of target
+func (c *DockerBuildCommand) imageTag(target string) string {
+	return fmt.Sprintf("%s%s%s:%s", c.Config.Container.ImagePrefix, path.Base(target), c.Config.Container.ImageSuffix, c.getDockerTag())
+}
+
+// images returns all images left after build, they are the local tags if
+// there is no registry
+func (c *DockerBuildCommand) images() []string {
+	images := []string{}
+	for _, target := range c.targets {
+		tag := c.imageTag(target)
+		if len(c.Config.Container.Registries) == 0 {
+			images = append(images, tag)
+			continue
+		}
+		for _, r := range c.Config.Container.Registries {
+			images = append(images, path.Join(r, tag))
+		}
+	}
+	return images
+}
+
 func (c *DockerBuildCommand) Run(cmd *cobra.Command, args []string) error {
+	env := map[string]string{
+		"MAKE_RULES_CONTAINER_TARGETS": strings.Join(c.targets, ","),
+		"MAKE_RULES_CONTAINER_IMAGES":  strings.Join(c.images(), ","),
+		"MAKE_RULES_CONTAINER_TAG":     c.getDockerTag(),
+	}
+	return c.RunWithHooks(context.Background(), "container-build", env, c.run)
+}
+
+func (c *DockerBuildCommand) run() error {
 	c.Logger.Info("=================================================")
 	c.Logger.Info("Docker build", "targets", c.targets)
 	for _, target := range c.targets {
 		dockerfile := path.Join(c.Workspace, target, "Dockerfile")
-		tag := fmt.Sprintf("%s%s%s:%s", c.Config.Container.ImagePrefix, path.Base(target), c.Config.Container.ImageSuffix, c.getDockerTag())
+		tag := c.imageTag(target)
 		c.Logger.Info("-------------------------------------------------")
-		c.Logger.Info("Docker build", "dockerfile", dockerfile, "tag", tag)
+		binary, err := c.binaryPath(target)
+		if err != nil {
+			return err
+		}
+		c.Logger.Info("Docker build", "dockerfile", dockerfile, "tag", tag, "binary", binary)
 
-		out, err := c.dockerRunner.RunCombinedOutput("build", "-f", dockerfile, "-t", tag, c.Workspace)
+		args := []string{"build", "-f", dockerfile, "-t", tag, "--build-arg", "MAKE_RULES_BINARY=" + binary}
+		if c.Config.Container.Platform != "" {
+			args = append(args, "--platform", c.Config.Container.Platform)
+		}
+		_, err = c.dockerRunner.WithLogger(c.Logger.WithValues("tag", tag), "Docker build output").
+			Run(context.Background(), append(args, c.Workspace)...)
 		if err != nil {
-			c.Logger.Error(err, "failed to build image", "output", string(out))
+			c.Logger.Error(err, "failed to build image")
 			return err
 		}
 		if len(c.Config.Container.Registries) == 0 {
@@ -133,7 +228,7 @@ func (c *DockerBuildCommand) Run(cmd *cobra.Command, args []string) error {
 			// new tag
 			newTag := path.Join(r, tag)
 			c.Logger.Info("Docker tag", "from", tag, "to", newTag)
-			out, err = c.dockerRunner.RunCombinedOutput("tag", tag, newTag)
+			out, err := c.dockerRunner.RunCombinedOutput("tag", tag, newTag)
 			if err != nil {
 				c.Logger.Error(err, "failt to tag image", "output", string(out))
 				return err
@@ -141,7 +236,7 @@ func (c *DockerBuildCommand) Run(cmd *cobra.Command, args []string) error {
 		}
 		// delete original tag
 		c.Logger.Info("Docker remove image", "image", tag)
-		out, err = c.dockerRunner.RunCombinedOutput("rmi", tag)
+		out, err := c.dockerRunner.RunCombinedOutput("rmi", tag)
 		if err != nil {
 			c.Logger.Error(err, "failt to delete image", "output", string(out))
 			return err
@@ -0,0 +1,145 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/zoumo/golib/cli"
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/cli/common"
+	"github.com/zoumo/make-rules/pkg/config"
+	goutil "github.com/zoumo/make-rules/pkg/golang"
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+func newTestDockerBuild(t *testing.T, docker *runner.Fake, registries ...string) *DockerBuildCommand {
+	cfg := config.New()
+	cfg.Container.ImagePrefix = "proj-"
+	cfg.Container.Registries = registries
+	output, err := goutil.ParseOutputTemplate(cfg.Go.Build.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &DockerBuildCommand{
+		CommonOptions: &common.CommonOptions{
+			CommonOptions: &cli.CommonOptions{Workspace: "/src/proj", Logger: log.Log},
+			Config:        cfg,
+		},
+		dockerRunner: docker,
+		targets:      []string{"cmd/foo"},
+		version:      "v1.0.0",
+		output:       output,
+	}
+}
+
+func TestDockerBuildCommand_Tag(t *testing.T) {
+	docker := runner.NewFake("docker")
+	docker.On("build", "...")
+	docker.On("tag", "*", "*")
+	docker.On("rmi", "*")
+
+	c := newTestDockerBuild(t, docker, "ghcr.io/a", "docker.io/a")
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, call := range docker.Calls()[1:] {
+		got = append(got, call.String())
+	}
+	want := []string{
+		"docker tag proj-foo:v1.0.0 ghcr.io/a/proj-foo:v1.0.0",
+		"docker tag proj-foo:v1.0.0 docker.io/a/proj-foo:v1.0.0",
+		"docker rmi proj-foo:v1.0.0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("docker commands = %v, want %v", got, want)
+	}
+	if images := c.images(); !reflect.DeepEqual(images, []string{"ghcr.io/a/proj-foo:v1.0.0", "docker.io/a/proj-foo:v1.0.0"}) {
+		t.Errorf("images() = %v", images)
+	}
+}
+
+func TestDockerBuildCommand_TagFailed(t *testing.T) {
+	docker := runner.NewFake("docker")
+	docker.On("build", "...")
+	docker.On("tag", "...").Return("", "denied", 1)
+	docker.On("rmi", "*")
+
+	c := newTestDockerBuild(t, docker, "ghcr.io/a")
+	if err := c.run(); err == nil {
+		t.Fatal("run() should fail if docker tag fails")
+	}
+	// the local image is kept if it is not tagged
+	if calls := docker.Calls(); len(calls) != 2 {
+		t.Errorf("docker commands = %v, want build and tag", calls)
+	}
+}
+
+func TestDockerBuildCommand_BinaryPath(t *testing.T) {
+	docker := runner.NewFake("docker")
+	docker.On("build", "...")
+
+	c := newTestDockerBuild(t, docker)
+	c.Config.Container.Platform = "linux/arm/v7"
+	c.Config.Go.Build.Profile = "release"
+	output, err := goutil.ParseOutputTemplate("bin/{{.Profile}}/{{.OS}}_{{.Arch}}_{{.Variant}}/{{.Name}}-{{.Version}}{{.Ext}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.output = output
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	want := "docker build -f /src/proj/cmd/foo/Dockerfile -t proj-foo:v1.0.0 --build-arg MAKE_RULES_BINARY=bin/release/linux_arm_v7/foo-v1.0.0 --platform linux/arm/v7 /src/proj"
+	if got := docker.Calls()[0].String(); got != want {
+		t.Errorf("docker build = %q, want %q", got, want)
+	}
+}
+
+func TestDockerBuildCommand_ProfileOutput(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, "build", "foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "build", "foo", "Dockerfile"), []byte("FROM scratch\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data := "container:\n  registries: [config.io]\n" +
+		"go:\n  build:\n    profiles:\n      release:\n        output: dist/{{.Profile}}/{{.Name}}{{.Ext}}\n"
+	if err := os.WriteFile(filepath.Join(workspace, config.ConfigPath), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	docker := runner.NewFake("docker")
+	// docker tags images into the registry
+	docker.On("...")
+	c := &DockerBuildCommand{
+		CommonOptions: common.NewCommonOptions(),
+		dockerRunner:  docker,
+	}
+	c.Workspace = workspace
+	cmd := &cobra.Command{Use: "build"}
+	c.BindFlags(cmd.Flags())
+	if err := cmd.Flags().Parse([]string{"--profile=release", "--version=v1.0.0", "--registries=flag.io"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Complete(cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	// flags take precedence over the config file
+	if got := c.Config.Container.Registries; !reflect.DeepEqual(got, []string{"flag.io"}) {
+		t.Errorf("registries = %v, want flag value", got)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	// binary is the output of go build with the same profile
+	if got := docker.Calls()[0].String(); !strings.Contains(got, "MAKE_RULES_BINARY=dist/release/foo ") {
+		t.Errorf("docker build = %q, want binary of release profile", got)
+	}
+}
@@ -0,0 +1,76 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/zoumo/golib/cli"
+
+	"github.com/zoumo/make-rules/pkg/cli/common"
+	"github.com/zoumo/make-rules/pkg/environ"
+)
+
+var _ cli.Command = &EnvCommand{}
+
+// EnvCommand prints the effective env of external commands run by
+// make-rules
+type EnvCommand struct {
+	*common.CommonOptions
+
+	command string
+	all     bool
+}
+
+func NewEnvCommand() *cobra.Command {
+	cmd := cli.NewCobraCommand(&EnvCommand{
+		CommonOptions: common.NewCommonOptions(),
+	})
+	cmd.Short = "Print the effective env of external commands"
+	cmd.Args = cobra.NoArgs
+	return cmd
+}
+
+func (c *EnvCommand) Name() string {
+	return "env"
+}
+
+func (c *EnvCommand) BindFlags(fs *pflag.FlagSet) {
+	c.CommonOptions.BindFlags(fs)
+	fs.StringVar(&c.command, "command", c.command, "print env of the command, it is the name used by hooks, e.g. build, mod-update, container-build")
+	fs.BoolVar(&c.all, "all", c.all, "print env inherited from process too")
+}
+
+func (c *EnvCommand) Run(cmd *cobra.Command, args []string) error {
+	env := c.Env
+	if c.command != "" {
+		var err error
+		env, err = environ.Resolve(c.Config.Env, c.Workspace, c.command, os.Environ(), environ.FlagEnv())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, v := range env.Vars() {
+		if v.Source == environ.SourceProcess && !c.all {
+			continue
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s=%s  # %s\n", v.Name, quote(v.Value), v.Source)
+	}
+	return nil
+}
+
+// quote quotes value if it can not be used in shell or dotenv file as is,
+// single quotes are preferred so that the value is not expanded again
+func quote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\"'#$\\`") {
+		return value
+	}
+	if !strings.ContainsAny(value, "'\n") {
+		return "'" + value + "'"
+	}
+	return strconv.Quote(value)
+}
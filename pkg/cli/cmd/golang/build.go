@@ -1,24 +1,33 @@
 package golang
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/zoumo/golib/cli"
+	"github.com/zoumo/golib/log"
 
 	"github.com/zoumo/make-rules/pkg/cli/cmd/utils"
 	"github.com/zoumo/make-rules/pkg/cli/common"
+	"github.com/zoumo/make-rules/pkg/config"
 	"github.com/zoumo/make-rules/pkg/git"
+	goutil "github.com/zoumo/make-rules/pkg/golang"
+	"github.com/zoumo/make-rules/pkg/hook"
 	"github.com/zoumo/make-rules/pkg/runner"
 	"github.com/zoumo/make-rules/version"
 )
 
 var RequiredGoEnvKeys = []string{
+	"CGO_ENABLED",
 	"GO111MODULE",
 	"GOFLAGS",
 	"GOINSECURE",
@@ -32,23 +41,6 @@ var RequiredGoEnvKeys = []string{
 	"GOSUMDB",
 }
 
-type platform struct {
-	GOOS   string
-	GOARCH string
-}
-
-func (p platform) String() string {
-	return p.GOOS + "/" + p.GOARCH
-}
-
-func readPlatform(val string) *platform {
-	ps := strings.SplitN(val, "/", 2)
-	if len(ps) != 2 {
-		return nil
-	}
-	return &platform{GOOS: ps[0], GOARCH: ps[1]}
-}
-
 var (
 	_ cli.Command        = &GobuildCommand{}
 	_ cli.ComplexOptions = &GobuildCommand{}
@@ -57,36 +49,111 @@ var (
 type GobuildCommand struct {
 	*common.CommonOptions
 
-	goCmd   *runner.Runner
-	bashCmd *runner.Runner
+	// goCmd and dockerCmd run go and docker, they are injected so that
+	// builds can be tested with runner.Fake
+	goCmd     runner.Executor
+	dockerCmd runner.Executor
+	hooks     *hook.Runner
 
 	allTargets []string
 	targets    []string
-	platforms  []platform
-	module     string
-
-	git     *git.Repository
-	version string
+	// platforms are all platforms of targets
+	platforms []platform
+	// platformsFlag is true if platforms are specified by flag, they
+	// overwrite platforms of all targets
+	platformsFlag bool
+	module        string
+	output        *goutil.OutputTemplate
+
+	git         *git.Repository
+	version     string
+	versionInfo version.Info
+
+	force     bool
+	cache     *buildCache
+	goVersion string
+
+	// flags are go build settings set by flags, cgo and env are set by
+	// flags too. The ones set in command line are merged into config in
+	// Complete, they take precedence over the config file.
+	flags config.GoBuild
+	cgo   bool
+	env   map[string]string
+
+	verifyReproducible bool
+	// verifyCache is the empty GOCACHE used to verify reproducible builds
+	verifyCache string
+
+	// reportFile is the path of build report, report is not written if it is empty
+	reportFile string
+	report     *BuildReport
+
+	// inContainer runs go build in onBuildImage container, container is
+	// initialized in Run if it is true
+	inContainer bool
+	container   *containerBuild
+
+	// planFormat is the format of build plan printed in dry run mode
+	planFormat string
+
+	// now returns the current time, it defaults to time.Now
+	now func() time.Time
 }
 
 func NewGobuildCommand() *cobra.Command {
-	return cli.NewCobraCommand(&GobuildCommand{
+	return cli.NewCobraCommand(newGobuildCommand())
+}
+
+// newGobuildCommand returns GobuildCommand running real go and docker, it
+// is shared by commands building targets
+func newGobuildCommand() *GobuildCommand {
+	return &GobuildCommand{
 		CommonOptions: common.NewCommonOptions(),
 		goCmd:         runner.NewRunner("go"),
-		bashCmd:       runner.NewRunner("bash"),
-	})
+		dockerCmd:     runner.NewRunner("docker"),
+		flags:         config.New().Go.Build,
+		// plan-format is bound by go build only, but validated by all
+		// commands sharing GobuildCommand
+		planFormat: PlanFormatText,
+	}
 }
 
 func (c *GobuildCommand) Name() string {
 	return "build"
 }
 
-func (c *GobuildCommand) BindFlags(fs *pflag.FlagSet) {
+// bindOutputFlags binds common flags and flags which select the platforms
+// and output paths of binaries, commands using binaries of go build, e.g.
+// install, package and sbom, bind only these flags
+func (c *GobuildCommand) bindOutputFlags(fs *pflag.FlagSet) {
 	// Call embedded CommonOptions.BindFlags first
 	c.CommonOptions.BindFlags(fs)
 
-	fs.StringSliceVar(&c.Config.Go.Build.Platforms, "platforms", c.Config.Go.Build.Platforms, "go build target platforms")
+	fs.StringSliceVar(&c.flags.Platforms, "platforms", c.flags.Platforms, "go build target platforms")
 	fs.StringVar(&c.version, "version", c.version, "go build target version")
+	fs.StringVar(&c.flags.Profile, "profile", c.flags.Profile, "name of go build profile in go.build.profiles")
+}
+
+func (c *GobuildCommand) BindFlags(fs *pflag.FlagSet) {
+	c.bindOutputFlags(fs)
+
+	fs.IntVarP(&c.flags.Parallelism, "jobs", "j", c.flags.Parallelism, "maximum number of (target, platform) builds running in parallel, logs of parallel builds are printed when each build finishes")
+	fs.BoolVar(&c.force, "force", c.force, "force rebuilding all targets, ignore the build cache")
+	fs.StringVar(&c.flags.BuildDate, "build-date", c.flags.BuildDate, "build date injected by ldflags, one of now, commit")
+	fs.StringSliceVar(&c.flags.Tags, "tags", c.flags.Tags, "go build tags")
+	fs.BoolVar(&c.cgo, "cgo", c.cgo, "set CGO_ENABLED, go default is used if it is not specified")
+	fs.BoolVar(&c.flags.Trimpath, "trimpath", c.flags.Trimpath, "remove all file system paths from the resulting executable")
+	fs.StringVar(&c.flags.BuildMode, "buildmode", c.flags.BuildMode, "go build mode")
+	fs.BoolVar(&c.flags.Static, "static", c.flags.Static, "build statically linked binaries")
+	fs.StringToStringVar(&c.env, "build-env", c.env, "extra env of go build, e.g. --build-env=GOEXPERIMENT=loopvar")
+	fs.BoolVar(&c.flags.Reproducible, "reproducible", c.flags.Reproducible, "build reproducible binaries and write their checksums")
+	fs.StringVar(&c.flags.HookTimeout, "hook-timeout", c.flags.HookTimeout, "maximum duration of one hook, e.g. 5m")
+	fs.BoolVar(&c.flags.SBOM, "sbom", c.flags.SBOM, "write CycloneDX and SPDX SBOMs next to every binary")
+	fs.BoolVar(&c.inContainer, "in-container", c.inContainer, "run go build in the onBuildImage container, defaults to true if onBuildImage is set")
+	fs.StringVar(&c.flags.OnBuildImage, "on-build-image", c.flags.OnBuildImage, "image with go toolchain used to build in container")
+	fs.StringVar(&c.planFormat, "plan-format", PlanFormatText, "format of build plan printed by --dry-run, one of text, json")
+	fs.StringVar(&c.reportFile, "report", c.reportFile, "write a JSON report of all built artifacts to the file")
+	fs.BoolVar(&c.verifyReproducible, "verify-reproducible", c.verifyReproducible, "build every binary twice and compare digests, it implies --reproducible and --force")
 }
 
 func (c *GobuildCommand) Complete(cmd *cobra.Command, args []string) error {
@@ -95,25 +162,46 @@ func (c *GobuildCommand) Complete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	for _, p := range c.Config.Go.Build.Platforms {
-		if pf := readPlatform(p); pf != nil {
-			c.platforms = append(c.platforms, *pf)
-		}
+	c.mergeFlags(cmd.Flags())
+	c.platformsFlag = cmd.Flags().Changed("platforms")
+	if c.reportFile != "" && !path.IsAbs(c.reportFile) {
+		c.reportFile = path.Join(c.Workspace, c.reportFile)
+	}
+	if c.verifyReproducible {
+		c.Config.Go.Build.Reproducible = true
+		c.force = true
+	}
+	if !cmd.Flags().Changed("in-container") {
+		c.inContainer = c.Config.Go.Build.OnBuildImage != ""
 	}
 
 	// find module
-	out, err := c.goCmd.RunOutput("list", "-m")
+	out, err := c.goCmd.ReadOnly().RunOutput("list", "-m")
 	if err != nil {
 		c.Logger.Error(err, string(out))
 		return err
 	}
 	c.module = strings.TrimSpace(string(out))
-	// no targets, walk cmd/ dir to find targets
-	allTargets, err := utils.FindTargetsFrom(c.Workspace, "cmd", "main.go")
+
+	c.Config.Go.Build, err = c.Config.Go.Build.WithProfile(c.Config.Go.Build.Profile)
+	if err != nil {
+		return err
+	}
+	c.output, err = goutil.ParseOutputTemplate(c.Config.Go.Build.Output)
 	if err != nil {
+		return fmt.Errorf("invalid go build output template %q: %w", c.Config.Go.Build.Output, err)
+	}
+
+	// find all main packages as targets
+	mains, err := utils.FindMainPackages(c.goCmd.WithDir(c.Workspace), c.module)
+	if err != nil {
+		c.Logger.Error(err, "failed to find main packages")
 		return err
 	}
-	c.allTargets = allTargets
+	c.allTargets = utils.MatchTargets(mains, c.Config.Go.Build.Targets.Include, c.Config.Go.Build.Targets.Exclude)
+	if err := c.Config.Go.Build.Targets.ResolveOverrides(c.allTargets); err != nil {
+		return fmt.Errorf("invalid go build config: %w", err)
+	}
 	c.targets = utils.FilterTargets(args, c.allTargets, "cmd")
 
 	if len(c.targets) == 0 {
@@ -121,22 +209,132 @@ func (c *GobuildCommand) Complete(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if err := c.expandPlatforms(); err != nil {
+		return err
+	}
+	seen := map[platform]bool{}
+	for _, t := range c.targets {
+		for _, p := range c.targetPlatforms(t) {
+			if !seen[p] {
+				seen[p] = true
+				c.platforms = append(c.platforms, p)
+			}
+		}
+	}
+
 	r, err := git.Open(c.Workspace)
 	if err != nil {
 		c.Logger.Info("worksapce is not a git repo", "workspace", c.Workspace)
 	} else {
 		c.git = r
 	}
+	// resolve version info once, it is shared by all builds
+	c.versionInfo = c.getVersionInfo()
 
-	c.Logger.Info("Before Go compiling", "platforms", c.Config.Go.Build.Platforms, "targets", c.targets)
+	c.Logger.Info("Before Go compiling", "platforms", c.platformStrings(), "targets", c.targets)
 	return nil
 }
 
+// mergeFlags merges go build settings of flags set in command line into
+// config, flags not bound by the command are never set
+func (c *GobuildCommand) mergeFlags(fs *pflag.FlagSet) {
+	b := &c.Config.Go.Build
+	merges := map[string]func(){
+		"platforms":      func() { b.Platforms = c.flags.Platforms },
+		"profile":        func() { b.Profile = c.flags.Profile },
+		"jobs":           func() { b.Parallelism = c.flags.Parallelism },
+		"build-date":     func() { b.BuildDate = c.flags.BuildDate },
+		"tags":           func() { b.Tags = c.flags.Tags },
+		"trimpath":       func() { b.Trimpath = c.flags.Trimpath },
+		"buildmode":      func() { b.BuildMode = c.flags.BuildMode },
+		"static":         func() { b.Static = c.flags.Static },
+		"reproducible":   func() { b.Reproducible = c.flags.Reproducible },
+		"hook-timeout":   func() { b.HookTimeout = c.flags.HookTimeout },
+		"sbom":           func() { b.SBOM = c.flags.SBOM },
+		"on-build-image": func() { b.OnBuildImage = c.flags.OnBuildImage },
+		"cgo":            func() { b.CGO = &c.cgo },
+		"build-env": func() {
+			if b.Env == nil {
+				b.Env = map[string]string{}
+			}
+			for k, v := range c.env {
+				b.Env[k] = v
+			}
+		},
+	}
+	fs.Visit(func(f *pflag.Flag) {
+		if merge, ok := merges[f.Name]; ok {
+			merge()
+		}
+	})
+}
+
 func (c *GobuildCommand) Validate() error {
 	// Call embedded CommonOptions.Validate first
 	if err := c.CommonOptions.Validate(); err != nil {
 		return err
 	}
+	if c.Config.Go.Build.Parallelism < 1 {
+		return fmt.Errorf("invalid jobs %d, it must be greater than 0", c.Config.Go.Build.Parallelism)
+	}
+	switch c.Config.Go.Build.BuildDate {
+	case config.BuildDateNow, config.BuildDateCommit:
+	default:
+		return fmt.Errorf("invalid build date %q, it must be one of %s, %s", c.Config.Go.Build.BuildDate, config.BuildDateNow, config.BuildDateCommit)
+	}
+	switch c.planFormat {
+	case PlanFormatText, PlanFormatJSON:
+	default:
+		return fmt.Errorf("invalid plan format %q, it must be one of %s, %s", c.planFormat, PlanFormatText, PlanFormatJSON)
+	}
+	if c.inContainer && c.Config.Go.Build.OnBuildImage == "" {
+		return fmt.Errorf("building in container requires onBuildImage")
+	}
+	if c.Config.Go.Build.HookTimeout != "" {
+		if _, err := time.ParseDuration(c.Config.Go.Build.HookTimeout); err != nil {
+			return fmt.Errorf("invalid hook timeout %q: %w", c.Config.Go.Build.HookTimeout, err)
+		}
+	}
+	if err := c.Config.Go.Build.Hooks.Validate(); err != nil {
+		return fmt.Errorf("invalid go build config: %w", err)
+	}
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); c.Config.Go.Build.Reproducible && epoch != "" {
+		if _, err := strconv.ParseInt(epoch, 10, 64); err != nil {
+			return fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", epoch, err)
+		}
+	}
+	// builds must not overwrite outputs of each other, e.g. variants with an
+	// output template without .Variant, or cmd/foo and tools/foo
+	type build struct {
+		target   string
+		platform platform
+	}
+	outputs := map[string]build{}
+	for _, t := range c.targets {
+		cfg := c.buildConfig(t)
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid go build config of target %s: %w", t, err)
+		}
+		if err := validateVersionVariables(cfg.VersionVariables); err != nil {
+			return fmt.Errorf("invalid go build config of target %s: %w", t, err)
+		}
+		if _, err := parseLDFlags(cfg.LDFlags); err != nil {
+			return fmt.Errorf("invalid go build config of target %s: %w", t, err)
+		}
+		for _, p := range c.targetPlatforms(t) {
+			output, err := c.outputFile(p, t)
+			if err != nil {
+				return fmt.Errorf("invalid go build output of target %s: %w", t, err)
+			}
+			if prev, ok := outputs[output]; ok {
+				if prev.target == t {
+					return fmt.Errorf("platforms %s and %s of target %s have the same output %s", prev.platform, p, t, output)
+				}
+				return fmt.Errorf("target %s (%s) and target %s (%s) have the same output %s", prev.target, prev.platform, t, p, output)
+			}
+			outputs[output] = build{target: t, platform: p}
+		}
+	}
 	return nil
 }
 
@@ -146,14 +344,11 @@ func (c *GobuildCommand) getVersionInfo() version.Info {
 		GitCommit:    "unknown",
 		GitTreeState: "unknown",
 		GitRemote:    "unknown",
-		BuildDate:    time.Now().UTC().Format(time.RFC3339),
-	}
-
-	if c.version != "" {
-		// use user defined version
-		info.GitVersion = c.version
+		BuildDate:    c.buildDate(),
+		BuildProfile: c.Config.Go.Build.Profile,
 	}
 
+	info.GitVersion = utils.GitVersion(c.Logger, c.git, c.version)
 	if c.git == nil {
 		// this is not a git repo
 		return info
@@ -173,20 +368,6 @@ func (c *GobuildCommand) getVersionInfo() version.Info {
 	}
 	info.GitTreeState = string(state)
 
-	if c.version == "" {
-		// get version from git describe
-		desc, err := c.git.Describe(head)
-		if err != nil {
-			c.Logger.Error(err, "failed to describe git for HEAD")
-			return info
-		}
-		info.GitVersion = desc.SemanticVersion()
-	}
-
-	if info.GitTreeState == string(git.GitTreeDirty) {
-		info.GitVersion += "-dirty"
-	}
-
 	remote, err := c.git.RemoteURL("origin")
 	if err != nil {
 		c.Logger.Error(err, "failed to get origin remote url from git")
@@ -196,122 +377,591 @@ func (c *GobuildCommand) getVersionInfo() version.Info {
 	return info
 }
 
-func (c *GobuildCommand) ldflags() string {
-	info := c.getVersionInfo()
+// buildDate returns the build date in RFC3339. In reproducible mode, it is
+// SOURCE_DATE_EPOCH or the committer time of HEAD, otherwise it is decided
+// by go.build.buildDate.
+func (c *GobuildCommand) buildDate() string {
+	date := c.clock().UTC()
+	mode := c.Config.Go.Build.BuildDate
+	if c.Config.Go.Build.Reproducible {
+		if sec, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC().Format(time.RFC3339)
+		}
+		mode = config.BuildDateCommit
+		// fallback to a fixed date if commit time is unavailable
+		date = time.Unix(0, 0).UTC()
+	}
+
+	if mode == config.BuildDateCommit && c.git != nil {
+		head, err := c.git.Head()
+		if err != nil {
+			c.Logger.Error(err, "failed to get head of git repo")
+			return date.Format(time.RFC3339)
+		}
+		commit, err := c.git.CommitObject(head.Hash())
+		if err != nil {
+			c.Logger.Error(err, "failed to get commit of HEAD")
+			return date.Format(time.RFC3339)
+		}
+		date = commit.Committer.When.UTC()
+	}
+	return date.Format(time.RFC3339)
+}
 
-	flags := []string{
-		fmt.Sprintf("-X github.com/zoumo/make-rules/version.buildDate=%s", info.BuildDate),
-		fmt.Sprintf("-X github.com/zoumo/make-rules/version.gitVersion=%s", info.GitVersion),
-		fmt.Sprintf("-X github.com/zoumo/make-rules/version.gitCommit=%s", info.GitCommit),
-		fmt.Sprintf("-X github.com/zoumo/make-rules/version.gitRemote=%s", info.GitRemote),
-		fmt.Sprintf("-X github.com/zoumo/make-rules/version.gitTreeState=%s", info.GitTreeState),
+// clock returns the current time by now
+func (c *GobuildCommand) clock() time.Time {
+	if c.now == nil {
+		return time.Now()
 	}
+	return c.now()
+}
 
-	flags = append(flags, c.Config.Go.Build.LDFlags...)
+// buildConfig returns go build config of target merged with its overrides in
+// go.build.targets
+func (c *GobuildCommand) buildConfig(target string) config.GoBuild {
+	cfg := c.Config.Go.Build.ForTarget(target)
+	if c.platformsFlag {
+		cfg.Platforms = c.Config.Go.Build.Platforms
+	}
+	if cfg.Reproducible {
+		if !hasFlag(cfg.Flags, "trimpath") {
+			cfg.Trimpath = true
+		}
+		if cfg.CGOEnabled() == "" {
+			// cgo builds depend on the host C toolchain
+			cfg.Env["CGO_ENABLED"] = "0"
+		}
+		// host values are cleared, so go defaults are used unless they are
+		// set in config or by platform variants
+		for _, k := range reproducibleEnvKeys {
+			if _, ok := cfg.Env[k]; !ok {
+				cfg.Env[k] = ""
+			}
+		}
+	}
+	return cfg
+}
+
+// expandPlatforms expands platform groups in global and target config, and
+// checks they are supported by go
+func (c *GobuildCommand) expandPlatforms() error {
+	ports, err := loadDistPorts(c.goCmd)
+	if err != nil {
+		c.Logger.Error(err, "failed to list platforms supported by go")
+		return err
+	}
+	c.Config.Go.Build.Platforms, err = expandPlatforms(c.Config.Go.Build.Platforms, ports)
+	if err != nil {
+		return err
+	}
+	for name, t := range c.Config.Go.Build.Targets.Overrides {
+		t.Platforms, err = expandPlatforms(t.Platforms, ports)
+		if err != nil {
+			return fmt.Errorf("invalid platforms of target %s: %w", name, err)
+		}
+		c.Config.Go.Build.Targets.Overrides[name] = t
+	}
+	return nil
+}
 
-	return strings.Join(flags, " ")
+// hasFlag returns true if flag name is in flags, e.g. -trimpath or -tags=foo
+func hasFlag(flags []string, name string) bool {
+	for _, f := range flags {
+		if strings.SplitN(strings.TrimLeft(f, "-"), "=", 2)[0] == name {
+			return true
+		}
+	}
+	return false
 }
 
-type HookPhase string
+func (c *GobuildCommand) targetPlatforms(target string) []platform {
+	return readPlatforms(c.buildConfig(target).Platforms)
+}
 
-const (
-	PreBuildHook  = "pre-build"
-	PostBuildHook = "post-build"
-)
+func (c *GobuildCommand) platformStrings() []string {
+	ps := []string{}
+	for _, p := range c.platforms {
+		ps = append(ps, p.String())
+	}
+	return ps
+}
 
-func (c *GobuildCommand) runHook(dir string, phase HookPhase) error {
-	// run global hooks
-	hook := path.Join(dir, string(phase))
-	c.Logger.V(2).Info("detecting hook", "path", hook)
-	if _, err := os.Stat(hook); err == nil {
-		outdirs := []string{}
-		for _, p := range c.platforms {
-			outdirs = append(outdirs, path.Dir(c.outputFile(p, "nothing")))
-		}
-		cmd := c.bashCmd.WithEnvs(
-			"MAKE_RULES_WORKSPACE", c.Workspace,
-			"MAKE_RULES_GO_BUILD_BINARY_DIRS", strings.Join(outdirs, ","),
-			"MAKE_RULES_GO_BUILD_PLATFORMS", strings.Join(c.Config.Go.Build.Platforms, ","),
-		)
-		c.Logger.Info("hook started", "phase", phase, "path", hook)
-		out, err := cmd.RunCombinedOutput(hook)
+// initHooks initializes hook runner with env shared by all hooks of tasks
+func (c *GobuildCommand) initHooks(tasks []buildTask) error {
+	// dirs of the real outputs, the output template may put targets in
+	// different dirs, e.g. bin/{{.Name}}/{{.OS}}_{{.Arch}}/{{.Name}}
+	outdirs := []string{}
+	seen := map[string]bool{}
+	for _, task := range tasks {
+		output, err := c.outputFile(task.platform, task.target)
 		if err != nil {
-			c.Logger.Error(err, string(out))
 			return err
 		}
-		c.Logger.Info("hook completed", "phase", phase, "output", string(out))
+		if dir := path.Dir(output); !seen[dir] {
+			seen[dir] = true
+			outdirs = append(outdirs, dir)
+		}
 	}
+	timeout, _ := time.ParseDuration(c.Config.Go.Build.HookTimeout)
+	c.hooks = (&hook.Runner{
+		Timeout:     timeout,
+		Dir:         c.Workspace,
+		Env:         c.HookEnv("build"),
+		NewExecutor: c.HookExecutor,
+	}).WithEnv(map[string]string{
+		"MAKE_RULES_MODULE":               c.module,
+		"MAKE_RULES_VERSION":              c.versionInfo.GitVersion,
+		"MAKE_RULES_GO_BUILD_BINARY_DIRS": strings.Join(outdirs, ","),
+		"MAKE_RULES_GO_BUILD_PLATFORMS":   strings.Join(c.platformStrings(), ","),
+		"MAKE_RULES_GO_BUILD_REPORT":      c.reportFile,
+		"MAKE_RULES_GO_BUILD_PROFILE":     c.Config.Go.Build.Profile,
+	})
 	return nil
 }
 
+// hookCondition returns the condition of hook steps running for platforms
+func (c *GobuildCommand) hookCondition(platforms ...string) hook.Condition {
+	return hook.Condition{
+		Platforms: platforms,
+		CleanTree: c.versionInfo.GitTreeState == string(git.GitTreeClean),
+	}
+}
+
+// runHooks runs hook files of phase found in dir, then hook steps
+func runHooks(ctx context.Context, logger log.Logger, hooks *hook.Runner, dir string, phase hook.Phase, steps []config.HookStep, cond hook.Condition, suffixes ...string) error {
+	if err := hooks.Run(ctx, logger, dir, phase, suffixes...); err != nil {
+		return err
+	}
+	return hooks.RunSteps(ctx, logger, phase, steps, cond)
+}
+
+// buildTask is a single go build of one target for one platform
+type buildTask struct {
+	// target is the target dir relative to workspace, e.g. cmd/foo
+	target   string
+	platform platform
+	// config is the go build config merged with target overrides
+	config config.GoBuild
+}
+
+// tasks returns builds of all targets for their platforms
+func (c *GobuildCommand) tasks() []buildTask {
+	tasks := []buildTask{}
+	for _, t := range c.targets {
+		cfg := c.buildConfig(t)
+		for _, platform := range readPlatforms(cfg.Platforms) {
+			tasks = append(tasks, buildTask{target: t, platform: platform, config: cfg})
+		}
+	}
+	return tasks
+}
+
+// taskEnv returns env overrides of go build of task
+func (c *GobuildCommand) taskEnv(task buildTask) map[string]string {
+	env := map[string]string{}
+	for k, v := range task.config.Env {
+		env[k] = v
+	}
+	if cgo := task.config.CGOEnabled(); cgo != "" {
+		env["CGO_ENABLED"] = cgo
+	}
+	env["GOOS"] = task.platform.GOOS
+	env["GOARCH"] = task.platform.GOARCH
+	env["WORKSPACE"] = c.Workspace
+	if kv := task.platform.variantEnv(); kv != nil {
+		env[kv[0]] = kv[1]
+	}
+	return env
+}
+
 func (c *GobuildCommand) Run(cmd *cobra.Command, args []string) error {
-	// run global hooks
-	if err := c.runHook(c.Config.Go.Build.GlobalHooksDir, PreBuildHook); err != nil {
+	if err := c.initGoVersion(); err != nil {
 		return err
 	}
+	// go build prints the plan in dry run mode, it is more readable than
+	// the go build commands logged by runner
+	if runner.DryRun() {
+		plan, err := c.plan()
+		if err != nil {
+			return err
+		}
+		return plan.Write(cmd.OutOrStdout(), c.planFormat)
+	}
+	return c.buildTasks(context.Background(), c.tasks())
+}
+
+// initGoVersion gets the version of local go. The go version of build image
+// is unknown in dry run, since getting it runs the image.
+func (c *GobuildCommand) initGoVersion() error {
+	if c.inContainer && runner.DryRun() {
+		c.goVersion = goVersionUnknown
+		return nil
+	}
+	out, err := c.goCmd.ReadOnly().RunOutput("env", "GOVERSION")
+	if err != nil {
+		return err
+	}
+	c.goVersion = strings.TrimSpace(string(out))
+	return nil
+}
 
-	for _, platform := range c.platforms {
-		c.Logger.Info("Go cross compiling", "platform", platform.String())
-		cmd := c.goCmd.WithEnvs(
-			"GOOS", platform.GOOS,
-			"GOARCH", platform.GOARCH,
-			"WORKSPACE", c.Workspace,
-		)
-		for _, t := range c.targets {
-			target := path.Join(c.module, t)
-			output := c.outputFile(platform, target)
-			hookDir := path.Join(c.Workspace, t)
-			// run pre build hook
-			if err := c.runHook(hookDir, PreBuildHook); err != nil {
+// buildTasks builds tasks with build cache, report and hooks, global hooks
+// run before and after all tasks. initGoVersion must be called first.
+func (c *GobuildCommand) buildTasks(ctx context.Context, tasks []buildTask) error {
+	var err error
+	if c.inContainer {
+		c.container, err = newContainerBuild(c.Config.Go.Build.OnBuildImage, c.Workspace, c.goCmd, c.dockerCmd)
+		if err != nil {
+			return err
+		}
+		// the build cache depends on go version of image, it is set by
+		// initGoVersion in dry run
+		if !runner.DryRun() {
+			c.goVersion, err = c.container.GoVersion()
+			if err != nil {
+				c.Logger.Error(err, "failed to get go version of build image", "image", c.Config.Go.Build.OnBuildImage)
 				return err
 			}
-			c.Logger.Info("Go build started", "module", target, "output", output)
-			// go build
-			args := c.gobuildArgs(output, target)
-			if c.Logger.V(2).Enabled() {
-				kvlist := []any{}
-				for k, v := range cmd.FilterEnv(RequiredGoEnvKeys) {
-					kvlist = append(kvlist, k, v)
+		}
+		c.Logger.Info("Go build in container", "image", c.Config.Go.Build.OnBuildImage, "goVersion", c.goVersion)
+	}
+	c.cache = loadBuildCache(path.Join(c.Workspace, "bin", BuildCacheFile))
+	c.report = &BuildReport{Workspace: c.Workspace, Module: c.module, Artifacts: []BuildArtifact{}}
+
+	if c.verifyReproducible {
+		c.verifyCache, err = os.MkdirTemp("", "make-rules-gocache.*")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(c.verifyCache)
+	}
+
+	if err := c.initHooks(tasks); err != nil {
+		return err
+	}
+	// run global hooks
+	globalCond := c.hookCondition(c.platformStrings()...)
+	if err := runHooks(ctx, c.Logger, c.hooks, c.Config.Go.Build.GlobalHooksDir, hook.PreBuild, c.Config.Go.Build.Hooks.Pre, globalCond); err != nil {
+		return err
+	}
+
+	err = c.runTasks(ctx, tasks)
+	if runner.DryRun() {
+		// nothing is built, cache and report are not written
+		if err != nil {
+			return err
+		}
+		return runHooks(ctx, c.Logger, c.hooks, c.Config.Go.Build.GlobalHooksDir, hook.PostBuild, c.Config.Go.Build.Hooks.Post, globalCond)
+	}
+	// save cache even if some builds failed, the succeeded ones can be skipped next time
+	if serr := c.cache.Save(); serr != nil {
+		c.Logger.Error(serr, "failed to save build cache")
+	}
+	if c.reportFile != "" {
+		if werr := c.report.Write(c.reportFile); werr != nil {
+			c.Logger.Error(werr, "failed to write build report", "file", c.reportFile)
+			if err == nil {
+				err = werr
+			}
+		} else {
+			c.Logger.Info("Go build report written", "file", c.reportFile)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// run global hooks
+	if err := runHooks(ctx, c.Logger, c.hooks, c.Config.Go.Build.GlobalHooksDir, hook.PostBuild, c.Config.Go.Build.Hooks.Post, globalCond); err != nil {
+		return err
+	}
+	return nil
+}
+
+// runTasks runs tasks with at most Parallelism workers. The first failed task
+// cancels all the others, and its error is returned.
+func (c *GobuildCommand) runTasks(ctx context.Context, tasks []buildTask) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := c.Config.Go.Build.Parallelism
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	// loggers are derived before workers start, deriving loggers from the
+	// shared logger is not safe for concurrent use
+	loggers := make([]log.Logger, len(tasks))
+	for i, task := range tasks {
+		loggers[i] = c.Logger.WithValues("target", task.target, "platform", task.platform.String())
+	}
+
+	// logs of parallel tasks are buffered in memory and flushed when the
+	// task finishes, so they are not interleaved. Only a progress line is
+	// logged when a task starts.
+	var buffered []*taskLogger
+	if workers > 1 {
+		buffered = make([]*taskLogger, len(tasks))
+		for i := range loggers {
+			buffered[i] = newTaskLogger(loggers[i])
+			loggers[i] = buffered[i]
+		}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		flushMu  sync.Mutex
+		firstErr error
+	)
+	queue := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if buffered != nil && ctx.Err() == nil {
+					flushMu.Lock()
+					// the underlying logger is not buffered
+					buffered[i].logger.Info(taskStartedMsg)
+					flushMu.Unlock()
+				}
+				err := c.build(ctx, loggers[i], tasks[i])
+				if buffered != nil {
+					flushMu.Lock()
+					buffered[i].Flush()
+					flushMu.Unlock()
+				}
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
 				}
-				kvlist = append(kvlist, "args", args)
-				c.Logger.Info("Go env and args", kvlist...)
 			}
-			out, err := cmd.RunCombinedOutput(args...)
-			if err != nil {
-				c.Logger.Error(err, string(out))
+		}()
+	}
+
+dispatch:
+	for i := range tasks {
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
+
+// build runs pre build hook, go build and post build hook for the task in
+// order, logger is the logger of the task
+func (c *GobuildCommand) build(ctx context.Context, logger log.Logger, task buildTask) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	target := path.Join(c.module, task.target)
+	output, err := c.outputFile(task.platform, target)
+	if err != nil {
+		return err
+	}
+	hookDir := path.Join(c.Workspace, task.target)
+	hooks := c.hooks.WithEnv(map[string]string{
+		"MAKE_RULES_TARGET":   task.target,
+		"MAKE_RULES_GOOS":     task.platform.GOOS,
+		"MAKE_RULES_GOARCH":   task.platform.GOARCH,
+		"MAKE_RULES_VARIANT":  task.platform.Variant,
+		"MAKE_RULES_PLATFORM": task.platform.String(),
+		"MAKE_RULES_OUTPUT":   output,
+	})
+	hookSuffixes := task.platform.hookSuffixes()
+	hookCond := c.hookCondition(task.platform.String())
+
+	envs := []string{}
+	for k, v := range c.taskEnv(task) {
+		envs = append(envs, k, v)
+	}
+	cmd := c.goCmd.WithEnvs(envs...)
+	// run pre build hook
+	if err := runHooks(ctx, logger, hooks, hookDir, hook.PreBuild, task.config.Hooks.Pre, hookCond, hookSuffixes...); err != nil {
+		return err
+	}
+	args, err := c.gobuildArgs(task, output, target)
+	if err != nil {
+		logger.Error(err, "failed to render go build args")
+		return err
+	}
+	key := buildCacheKey(task)
+	env := buildEnv(cmd, task.config)
+	buildDateSymbol := versionSymbol(task.config.VersionPackage, task.config.VersionVariables, "buildDate")
+	inputs, err := buildInputs(cmd, c.goVersion, target, cacheArgs(args, buildDateSymbol), env)
+	if err != nil {
+		logger.Error(err, "failed to compute build inputs")
+		return err
+	}
+	artifact := BuildArtifact{
+		Target:   task.target,
+		Platform: task.platform.String(),
+		Output:   output,
+		Version:  c.taskVersionInfo(task),
+		Args:     args,
+		Env:      env,
+	}
+	var (
+		entry buildCacheEntry
+		hit   bool
+	)
+	if !c.force {
+		entry, hit = c.cache.Hit(key, inputs, output)
+	}
+	if hit {
+		logger.Info("Go build skipped, output is up to date", "module", target, "output", output)
+		if runner.DryRun() {
+			return runHooks(ctx, logger, hooks, hookDir, hook.PostBuild, task.config.Hooks.Post, hookCond, hookSuffixes...)
+		}
+		artifact.Cached = true
+		// the cached output is not rebuilt, it keeps its own build date
+		artifact.Version = *entry.Version
+		if task.config.SBOM {
+			if artifact.SBOM, err = c.writeSBOM(artifact.Output, artifact.Version); err != nil {
+				logger.Error(err, "failed to write SBOM")
 				return err
 			}
-			c.Logger.Info("Go build completed", "module", target)
-			// run post build hook
-			if err := c.runHook(hookDir, PostBuildHook); err != nil {
+		}
+		if err := c.report.Add(artifact); err != nil {
+			return err
+		}
+		return runHooks(ctx, logger, hooks, hookDir, hook.PostBuild, task.config.Hooks.Post, hookCond, hookSuffixes...)
+	}
+
+	logger.Info("Go build started", "module", target, "output", output)
+	// go build
+	if logger.V(2).Enabled() {
+		kvlist := []any{}
+		for k, v := range cmd.FilterEnv(RequiredGoEnvKeys) {
+			kvlist = append(kvlist, k, v)
+		}
+		kvlist = append(kvlist, "args", args)
+		logger.Info("Go env and args", kvlist...)
+	}
+	start := time.Now()
+	if err := c.runGo(ctx, logger, cmd, env, output, args); err != nil {
+		if ctx.Err() != nil {
+			logger.Info("Go build canceled", "module", target)
+			return err
+		}
+		logger.Error(err, "Go build failed", "module", target)
+		return err
+	}
+	if runner.DryRun() {
+		// go build is not executed, there is no output to record
+		return runHooks(ctx, logger, hooks, hookDir, hook.PostBuild, task.config.Hooks.Post, hookCond, hookSuffixes...)
+	}
+	artifact.Duration = time.Since(start).Seconds()
+	if err := c.cache.Set(key, inputs, output, artifact.Version); err != nil {
+		logger.Error(err, "failed to record output in build cache")
+		return err
+	}
+	logger.Info("Go build completed", "module", target)
+	if task.config.Reproducible {
+		digest, err := writeChecksum(output)
+		if err != nil {
+			logger.Error(err, "failed to write checksum")
+			return err
+		}
+		if c.verifyReproducible {
+			if err := c.verify(ctx, logger, task, cmd, target, digest); err != nil {
 				return err
 			}
 		}
 	}
-	// run global hooks
-	if err := c.runHook(c.Config.Go.Build.GlobalHooksDir, PostBuildHook); err != nil {
+	if task.config.SBOM {
+		if artifact.SBOM, err = c.writeSBOM(output, artifact.Version); err != nil {
+			logger.Error(err, "failed to write SBOM")
+			return err
+		}
+	}
+	if err := c.report.Add(artifact); err != nil {
 		return err
 	}
-	return nil
+	// run post build hook
+	return runHooks(ctx, logger, hooks, hookDir, hook.PostBuild, task.config.Hooks.Post, hookCond, hookSuffixes...)
 }
 
-func (c *GobuildCommand) gobuildArgs(output, target string) []string {
+// runGo runs go with args locally, or in build container if it is enabled.
+// Output of go is written to logger, which buffers it if tasks run in
+// parallel. env are passed into container, output is the output path of go
+// build.
+func (c *GobuildCommand) runGo(ctx context.Context, logger log.Logger, cmd runner.Executor, env map[string]string, output string, args []string) error {
+	if c.container != nil {
+		return c.container.Run(ctx, logger, env, output, args...)
+	}
+	_, err := cmd.WithLogger(logger, "Go build output").Run(ctx, args...)
+	return err
+}
+
+// buildEnv returns go env of build which affects outputs, they are
+// RequiredGoEnvKeys, GOOS, GOARCH, GOEXPERIMENT and extra env of cfg
+func buildEnv(cmd runner.Executor, cfg config.GoBuild) map[string]string {
+	keys := append([]string{}, cacheEnvKeys...)
+	for k := range cfg.Env {
+		keys = append(keys, k)
+	}
+	return cmd.FilterEnv(keys)
+}
+
+func (c *GobuildCommand) gobuildArgs(task buildTask, output, target string) ([]string, error) {
+	cfg := task.config
+	ldflags, err := c.ldflags(task)
+	if err != nil {
+		return nil, err
+	}
 	args := []string{"build"}
-	if len(c.Config.Go.Build.Flags) > 0 {
-		args = append(args, c.Config.Go.Build.Flags...)
+	if len(cfg.Flags) > 0 {
+		args = append(args, cfg.Flags...)
+	}
+	if len(cfg.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(cfg.Tags, ","))
+	}
+	if cfg.Trimpath {
+		args = append(args, "-trimpath")
 	}
-	args = append(args, "-ldflags", c.ldflags())
-	if len(c.Config.Go.Build.GCFlags) > 0 {
-		args = append(args, "-gcflags", strings.Join(c.Config.Go.Build.GCFlags, " "))
+	if cfg.BuildMode != "" {
+		args = append(args, "-buildmode", cfg.BuildMode)
+	}
+	args = append(args, "-ldflags", ldflags)
+	if len(cfg.GCFlags) > 0 {
+		args = append(args, "-gcflags", strings.Join(cfg.GCFlags, " "))
 	}
 	args = append(args, "-o", output, target)
-	return args
+	return args, nil
 }
 
-func (c *GobuildCommand) outputFile(platform platform, target string) string {
-	bin := path.Base(target)
-	if platform.GOOS == "windown" {
-		bin += ".exe"
+// targetName returns the binary name of target, which is a dir relative to
+// workspace or a package path. It is the project name for the main package
+// in module root.
+func (c *GobuildCommand) targetName(target string) string {
+	if target == "." || target == c.module {
+		return projectName(c.module)
 	}
-	return fmt.Sprintf("%s/bin/%s_%s/%s", c.Workspace, platform.GOOS, platform.GOARCH, bin)
+	return path.Base(target)
+}
+
+// majorVersionSuffix matches the major version suffix of module path, e.g. v2
+var majorVersionSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// projectName returns the name of module without major version suffix, e.g.
+// bar of github.com/foo/bar/v2
+func projectName(module string) string {
+	name := path.Base(module)
+	if majorVersionSuffix.MatchString(name) {
+		name = path.Base(path.Dir(module))
+	}
+	return name
+}
+
+// outputFile renders the output path of target for platform by go.build.output template
+func (c *GobuildCommand) outputFile(platform platform, target string) (string, error) {
+	return c.output.Binary(c.Workspace, platform.String(), c.targetName(target), c.versionInfo.GitVersion, c.Config.Go.Build.Profile)
 }
@@ -0,0 +1,189 @@
+package golang
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zoumo/make-rules/pkg/runner"
+	"github.com/zoumo/make-rules/version"
+)
+
+const (
+	// BuildCacheFile is the manifest file name of build cache under <workspace>/bin/
+	BuildCacheFile = ".make-rules-cache.json"
+)
+
+// goListDepsFiles prints the dir and all input files of each non-standard
+// package that the target depends on, one package per line
+const goListDepsFiles = `{{if not .Standard}}{{.Dir}}` +
+	`{{range .GoFiles}}	{{.}}{{end}}{{range .CgoFiles}}	{{.}}{{end}}` +
+	`{{range .CFiles}}	{{.}}{{end}}{{range .CXXFiles}}	{{.}}{{end}}` +
+	`{{range .HFiles}}	{{.}}{{end}}{{range .SFiles}}	{{.}}{{end}}` +
+	`{{range .SysoFiles}}	{{.}}{{end}}{{range .EmbedFiles}}	{{.}}{{end}}` +
+	"\n{{end}}"
+
+// cacheEnvKeys are env keys which affect go build outputs
+var cacheEnvKeys = append([]string{"GOOS", "GOARCH", "GOARM", "GOAMD64", "GO386", "GOEXPERIMENT"}, RequiredGoEnvKeys...)
+
+// buildCache is a content-addressed manifest recording the inputs digest of
+// every (target, platform) output.
+type buildCache struct {
+	file string
+	mu   sync.Mutex
+
+	Entries map[string]buildCacheEntry `json:"entries"`
+}
+
+type buildCacheEntry struct {
+	// Inputs is the sha256 digest of all build inputs
+	Inputs string `json:"inputs"`
+	// Output is the path of built binary
+	Output string `json:"output"`
+	// Digest is the sha256 digest of output when it is built
+	Digest string `json:"digest"`
+	// Version is the version info injected into output, a cached output
+	// keeps the build date of the build producing it
+	Version *version.Info `json:"version,omitempty"`
+}
+
+// loadBuildCache loads build cache from file, an empty cache is returned if
+// the file does not exist or is corrupted.
+func loadBuildCache(file string) *buildCache {
+	bc := &buildCache{
+		file:    file,
+		Entries: map[string]buildCacheEntry{},
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return bc
+	}
+	if err := json.Unmarshal(data, bc); err != nil || bc.Entries == nil {
+		bc.Entries = map[string]buildCacheEntry{}
+	}
+	return bc
+}
+
+func buildCacheKey(task buildTask) string {
+	return task.target + "@" + task.platform.String()
+}
+
+// Get returns the entry of key
+func (bc *buildCache) Get(key string) (buildCacheEntry, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	entry, ok := bc.Entries[key]
+	return entry, ok
+}
+
+// Hit returns the entry of key and true if the recorded inputs of key equal
+// to inputs and the output still exists and is unchanged since it is built.
+// Entries without version info never hit.
+func (bc *buildCache) Hit(key, inputs, output string) (buildCacheEntry, bool) {
+	entry, ok := bc.Get(key)
+	if !ok || entry.Inputs != inputs || entry.Output != output || entry.Version == nil {
+		return entry, false
+	}
+	digest, err := fileSHA256(output)
+	return entry, err == nil && digest == entry.Digest
+}
+
+// Set records inputs, the digest and version info of output built for key
+func (bc *buildCache) Set(key, inputs, output string, info version.Info) error {
+	digest, err := fileSHA256(output)
+	if err != nil {
+		return err
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.Entries[key] = buildCacheEntry{Inputs: inputs, Output: output, Digest: digest, Version: &info}
+	return nil
+}
+
+func (bc *buildCache) Save() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	data, err := json.MarshalIndent(bc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(bc.file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(bc.file, data, 0644)
+}
+
+// cacheArgs returns go build args used in cache key. The value of build date
+// symbol in ldflags is left out, since it changes on every run with the
+// default buildDate: now, so a cached binary keeps the build date of the
+// build producing it.
+func cacheArgs(args []string, buildDateSymbol string) []string {
+	if buildDateSymbol == "" {
+		return args
+	}
+	re := regexp.MustCompile(`(-X[= ]?` + regexp.QuoteMeta(buildDateSymbol) + `=)\S*`)
+	normalized := make([]string, len(args))
+	for i, arg := range args {
+		if i > 0 && args[i-1] == "-ldflags" {
+			arg = re.ReplaceAllString(arg, "${1}")
+		}
+		normalized[i] = arg
+	}
+	return normalized
+}
+
+// buildInputs returns the sha256 digest of all inputs of go build: the go
+// version, go build args (including ldflags and gcflags), go env and the
+// content of every file in non-standard packages which target depends on.
+func buildInputs(cmd runner.Executor, goVersion, target string, args []string, env map[string]string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "go:%s\n", goVersion)
+	fmt.Fprintf(h, "args:%s\n", strings.Join(args, "\x00"))
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "env:%s=%s\n", k, env[k])
+	}
+
+	out, err := cmd.ReadOnly().RunOutput("list", "-deps", "-f", goListDepsFiles, target)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) == 0 || fields[0] == "" {
+			continue
+		}
+		dir := fields[0]
+		for _, f := range fields[1:] {
+			if err := hashFile(h, filepath.Join(dir, f)); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintf(w, "file:%s\n", file)
+	_, err = io.Copy(w, f)
+	return err
+}
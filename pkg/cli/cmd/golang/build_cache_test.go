@@ -0,0 +1,177 @@
+package golang
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zoumo/make-rules/pkg/runner"
+	"github.com/zoumo/make-rules/pkg/sbom"
+	"github.com/zoumo/make-rules/version"
+)
+
+func TestBuildCache(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "linux_amd64", "foo")
+	file := filepath.Join(dir, BuildCacheFile)
+	key := buildCacheKey(buildTask{target: "cmd/foo", platform: platform{GOOS: "linux", GOARCH: "amd64"}})
+
+	bc := loadBuildCache(file)
+	if _, hit := bc.Hit(key, "inputs", output); hit {
+		t.Fatal("empty cache should not hit")
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(output, []byte("bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.Set(key, "inputs", output, version.Info{BuildDate: "2020-01-01T00:00:00Z"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	bc = loadBuildCache(file)
+	if entry, hit := bc.Hit(key, "inputs", output); !hit || entry.Version.BuildDate != "2020-01-01T00:00:00Z" {
+		t.Error("cache should hit with the recorded version info if inputs are unchanged and output exists")
+	}
+	if _, hit := bc.Hit(key, "changed", output); hit {
+		t.Error("cache should not hit if inputs are changed")
+	}
+
+	if err := os.WriteFile(output, []byte("signed bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit := bc.Hit(key, "inputs", output); hit {
+		t.Error("cache should not hit if output is changed")
+	}
+
+	if err := os.Remove(output); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit := bc.Hit(key, "inputs", output); hit {
+		t.Error("cache should not hit if output does not exist")
+	}
+}
+
+func TestCacheArgs(t *testing.T) {
+	args := []string{"build", "-ldflags", "-X v.buildDate=2020-01-01T00:00:00Z -X v.gitVersion=v1.0.0 -s", "-o", "bin/foo", "example.com/proj/cmd/foo"}
+	want := []string{"build", "-ldflags", "-X v.buildDate= -X v.gitVersion=v1.0.0 -s", "-o", "bin/foo", "example.com/proj/cmd/foo"}
+	if got := cacheArgs(args, "v.buildDate"); !reflect.DeepEqual(got, want) {
+		t.Errorf("cacheArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestGobuildCommand_CacheHit(t *testing.T) {
+	workspace := t.TempDir()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	build := func() int {
+		goCmd := fakeGo()
+		err := runTestBuild(workspace, []string{"--platforms=linux/amd64", "cmd/foo"},
+			withGo(goCmd), withClock(func() time.Time { return now }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		builds := 0
+		for _, c := range goCmd.Calls() {
+			if c.Args[0] == "build" {
+				builds++
+			}
+		}
+		return builds
+	}
+
+	if n := build(); n != 1 {
+		t.Fatalf("first run built %d binaries, want 1", n)
+	}
+	// the default build date is now, it is changed in the second run
+	now = now.Add(time.Hour)
+	if n := build(); n != 0 {
+		t.Errorf("second run built %d binaries, want cache hit", n)
+	}
+}
+
+// copyTestBinary copies the running test binary to output, it is a go binary
+// with build info, e.g. for SBOM
+func copyTestBinary(output string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(self)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func TestGobuildCommand_CacheHitBuildDate(t *testing.T) {
+	workspace := t.TempDir()
+	first := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := first
+	build := func() BuildArtifact {
+		t.Helper()
+		goCmd := fakeGoWithoutBuild("cmd/foo")
+		goCmd.On("build", "...").Do(func(c runner.Call) error {
+			return copyTestBinary(c.Args[len(c.Args)-2])
+		})
+		err := runTestBuild(workspace, []string{"--report=report.json", "--sbom", "--platforms=linux/amd64", "cmd/foo"},
+			withGo(goCmd), withClock(func() time.Time { return now }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(workspace, "report.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := BuildReport{}
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Artifacts) != 1 {
+			t.Fatalf("report has %d artifacts, want 1", len(report.Artifacts))
+		}
+		return report.Artifacts[0]
+	}
+
+	build()
+	now = now.Add(time.Hour)
+	artifact := build()
+	want := first.Format(time.RFC3339)
+	if !artifact.Cached || artifact.Version.BuildDate != want {
+		t.Errorf("artifact of the second build has cached %v and build date %s, want cached with %s", artifact.Cached, artifact.Version.BuildDate, want)
+	}
+	data, err := os.ReadFile(artifact.Output + sbom.CycloneDXExt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom := struct {
+		Metadata struct {
+			Timestamp string `json:"timestamp"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(data, &bom); err != nil {
+		t.Fatal(err)
+	}
+	if bom.Metadata.Timestamp != want {
+		t.Errorf("SBOM timestamp of the second build = %s, want %s", bom.Metadata.Timestamp, want)
+	}
+}
@@ -0,0 +1,127 @@
+package golang
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+// goVersionUnknown is the go version of build image in dry run
+const goVersionUnknown = "unknown"
+
+// containerEnvExcludes are go env keys of host paths which are not passed
+// into build container
+var containerEnvExcludes = []string{"GOMOD", "GOPATH", "GOROOT"}
+
+// containerBuild runs go commands in the onBuildImage container. The
+// workspace and go caches are mounted at the same paths as host, so that
+// outputs, -trimpath and the build cache behave the same as local builds.
+type containerBuild struct {
+	image     string
+	docker    runner.Executor
+	workspace string
+	// goCache and goModCache are the host GOCACHE and GOMODCACHE
+	goCache    string
+	goModCache string
+}
+
+// newContainerBuild creates containerBuild running go by docker, the go
+// caches are read from host go env
+func newContainerBuild(image, workspace string, goCmd, docker runner.Executor) (*containerBuild, error) {
+	out, err := goCmd.ReadOnly().RunOutput("env", "GOCACHE", "GOMODCACHE")
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	b := &containerBuild{
+		image:     image,
+		docker:    docker,
+		workspace: workspace,
+	}
+	if len(lines) == 2 {
+		b.goCache, b.goModCache = lines[0], lines[1]
+	}
+	return b, nil
+}
+
+// args returns docker run args running go with args in container. env are
+// passed into container, GOCACHE in env overrides the host cache. The dir
+// of output is mounted too if it is out of workspace.
+func (b *containerBuild) args(env map[string]string, output string, goArgs ...string) []string {
+	env = b.env(env)
+	mounts := []string{b.workspace}
+	for _, dir := range []string{env["GOCACHE"], env["GOMODCACHE"]} {
+		if dir != "" {
+			mounts = append(mounts, dir)
+		}
+	}
+	if output != "" && !isSubPath(b.workspace, output) {
+		mounts = append(mounts, filepath.Dir(output))
+	}
+
+	args := []string{"run", "--rm", "-w", b.workspace}
+	if runtime.GOOS == "linux" {
+		// write outputs and caches as the current user
+		args = append(args, "--user", strconv.Itoa(os.Getuid())+":"+strconv.Itoa(os.Getgid()))
+	}
+	for _, m := range mounts {
+		args = append(args, "-v", m+":"+m)
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "-e", k+"="+env[k])
+	}
+	args = append(args, b.image, "go")
+	return append(args, goArgs...)
+}
+
+// env returns env passed into container, host paths are removed and go
+// caches are set
+func (b *containerBuild) env(env map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range env {
+		result[k] = v
+	}
+	for _, k := range containerEnvExcludes {
+		delete(result, k)
+	}
+	if _, ok := result["GOCACHE"]; !ok && b.goCache != "" {
+		result["GOCACHE"] = b.goCache
+	}
+	if _, ok := result["GOMODCACHE"]; !ok && b.goModCache != "" {
+		result["GOMODCACHE"] = b.goModCache
+	}
+	return result
+}
+
+// Run runs go with args in container, its output is streamed to logger
+func (b *containerBuild) Run(ctx context.Context, logger log.Logger, env map[string]string, output string, goArgs ...string) error {
+	_, err := b.docker.WithLogger(logger, "Go build output").Run(ctx, b.args(env, output, goArgs...)...)
+	return err
+}
+
+// GoVersion returns the go version of image
+func (b *containerBuild) GoVersion() (string, error) {
+	out, err := b.docker.ReadOnly().RunOutput("run", "--rm", b.image, "go", "env", "GOVERSION")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func isSubPath(parent, child string) bool {
+	rel, err := filepath.Rel(parent, child)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,99 @@
+package golang
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+func TestContainerBuild(t *testing.T) {
+	docker := runner.NewFake("docker")
+	docker.On("run", "--rm", "golang:1.99", "go", "env", "GOVERSION").Return("go1.99.0\n", "", 0)
+	docker.On("run", "...")
+	b := &containerBuild{
+		image:      "golang:1.99",
+		docker:     docker,
+		workspace:  "/src/proj",
+		goCache:    "/cache/go-build",
+		goModCache: "/cache/mod",
+	}
+
+	version, err := b.GoVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "go1.99.0" {
+		t.Errorf("GoVersion() = %q, want go1.99.0", version)
+	}
+
+	env := map[string]string{"GOOS": "linux", "GOROOT": "/usr/local/go", "GOCACHE": "/tmp/verify"}
+	err = b.Run(context.Background(), log.Log, env, "/tmp/out/foo", "build", "-o", "/tmp/out/foo", "./cmd/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := docker.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("docker is called %d times, want 2", len(calls))
+	}
+	got := calls[1].String()
+	for _, want := range []string{
+		"docker run --rm -w /src/proj",
+		"-v /src/proj:/src/proj",
+		"-v /tmp/verify:/tmp/verify",
+		"-v /cache/mod:/cache/mod",
+		"-v /tmp/out:/tmp/out",
+		"-e GOCACHE=/tmp/verify -e GOMODCACHE=/cache/mod -e GOOS=linux",
+		"golang:1.99 go build -o /tmp/out/foo ./cmd/foo",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("docker args %q should contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "GOROOT") || strings.Contains(got, "/cache/go-build") {
+		t.Errorf("docker args %q should not contain host GOROOT or overridden GOCACHE", got)
+	}
+}
+
+func TestGobuildCommand_DryRunInContainer(t *testing.T) {
+	runner.SetDryRun(true)
+	defer runner.SetDryRun(false)
+
+	goCmd := fakeGo()
+	goCmd.On("env", "GOCACHE", "GOMODCACHE").Return("/cache/go-build\n/cache/mod\n", "", 0)
+	docker := runner.NewFake("docker")
+	docker.On("run", "...")
+	cfg := config.New()
+	cfg.Go.Build.OnBuildImage = "golang:1.99"
+	c := newTestGobuild(t.TempDir(), withConfig(cfg), withGo(goCmd), withDocker(docker))
+	cmd := completeTestBuild(t, c, "--platforms=linux/amd64", "--plan-format=json")
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	if err := c.Run(cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	plan := BuildPlan{}
+	if err := json.Unmarshal(out.Bytes(), &plan); err != nil {
+		t.Fatal(err)
+	}
+	if plan.Version.GoVersion != goVersionUnknown {
+		t.Errorf("go version in plan = %q, want %q", plan.Version.GoVersion, goVersionUnknown)
+	}
+
+	// commands building on demand, e.g. go install, do not probe the build
+	// image, read-only commands still run in dry run
+	if err := c.buildTasks(context.Background(), c.tasks()); err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range docker.Calls() {
+		if call.ReadOnly {
+			t.Errorf("read-only docker command runs in dry run: %s", call)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package golang
+
+import (
+	"sync"
+
+	"github.com/zoumo/golib/log"
+)
+
+// taskStartedMsg is the progress line logged unbuffered when a parallel
+// build task starts, its other logs are buffered by taskLogger
+const taskStartedMsg = "Go build task started, logs are printed when it finishes"
+
+// taskLogger is a logger buffering all logs of a build task, including the
+// output of go build and hooks. Logs of parallel tasks are flushed one task
+// after another, so the output of each build stays grouped. All logs of a
+// task are held in memory until it finishes.
+type taskLogger struct {
+	logger log.Logger
+	buf    *logBuffer
+}
+
+// logBuffer is shared by a taskLogger and loggers derived from it
+type logBuffer struct {
+	mu   sync.Mutex
+	logs []func()
+}
+
+// newTaskLogger returns a taskLogger buffering logs of logger
+func newTaskLogger(logger log.Logger) *taskLogger {
+	return &taskLogger{logger: logger, buf: &logBuffer{}}
+}
+
+func (l *taskLogger) add(fn func()) {
+	l.buf.mu.Lock()
+	defer l.buf.mu.Unlock()
+	l.buf.logs = append(l.buf.logs, fn)
+}
+
+// Flush writes buffered logs to the underlying logger in order
+func (l *taskLogger) Flush() {
+	l.buf.mu.Lock()
+	defer l.buf.mu.Unlock()
+	for _, fn := range l.buf.logs {
+		fn()
+	}
+	l.buf.logs = nil
+}
+
+func (l *taskLogger) Enabled() bool {
+	return l.logger.Enabled()
+}
+
+func (l *taskLogger) Info(msg string, keysAndValues ...any) {
+	logger := l.logger
+	l.add(func() { logger.Info(msg, keysAndValues...) })
+}
+
+func (l *taskLogger) Error(err error, msg string, keysAndValues ...any) {
+	logger := l.logger
+	l.add(func() { logger.Error(err, msg, keysAndValues...) })
+}
+
+func (l *taskLogger) V(level int) log.Logger {
+	return &taskLogger{logger: l.logger.V(level), buf: l.buf}
+}
+
+func (l *taskLogger) WithValues(keysAndValues ...any) log.Logger {
+	return &taskLogger{logger: l.logger.WithValues(keysAndValues...), buf: l.buf}
+}
+
+func (l *taskLogger) WithName(name string) log.Logger {
+	return &taskLogger{logger: l.logger.WithName(name), buf: l.buf}
+}
@@ -0,0 +1,30 @@
+package golang
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTaskLogger(t *testing.T) {
+	logger := newRecordLogger()
+	l := newTaskLogger(logger.WithValues("target", "cmd/foo"))
+	l.Info("started")
+	// derived loggers share the buffer of task
+	l.V(2).WithName("go").Info("output")
+	l.WithValues("phase", "post-build").Error(errors.New("failed"), "hook failed")
+	if len(*logger.logs) != 0 {
+		t.Fatalf("logs are written before flush: %v", *logger.logs)
+	}
+
+	l.Flush()
+	want := []string{"started cmd/foo", "output cmd/foo", "hook failed cmd/foo"}
+	if !reflect.DeepEqual(*logger.logs, want) {
+		t.Errorf("flushed logs = %v, want %v", *logger.logs, want)
+	}
+	// flushed logs are not written again
+	l.Flush()
+	if len(*logger.logs) != len(want) {
+		t.Errorf("logs are flushed twice: %v", *logger.logs)
+	}
+}
@@ -0,0 +1,162 @@
+package golang
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/hook"
+	"github.com/zoumo/make-rules/version"
+)
+
+const (
+	PlanFormatText = "text"
+	PlanFormatJSON = "json"
+)
+
+// BuildPlan is the resolved matrix of go build printed by --dry-run
+type BuildPlan struct {
+	Workspace string       `json:"workspace"`
+	Module    string       `json:"module"`
+	Version   version.Info `json:"version"`
+	// Hooks are global hooks run before and after all builds
+	Hooks  PlannedHooks   `json:"hooks"`
+	Builds []PlannedBuild `json:"builds"`
+}
+
+// PlannedBuild is the go build of one (target, platform)
+type PlannedBuild struct {
+	Target   string `json:"target"`
+	Platform string `json:"platform"`
+	Output   string `json:"output"`
+	// Args are the go build args including the rendered ldflags
+	Args []string `json:"args"`
+	// Env are env overrides of go build
+	Env   map[string]string `json:"env"`
+	Hooks PlannedHooks      `json:"hooks"`
+}
+
+// PlannedHooks are hooks which would fire, hook files are paths relative to
+// workspace, hook steps are "step:<name>"
+type PlannedHooks struct {
+	Pre  []string `json:"pre"`
+	Post []string `json:"post"`
+}
+
+// plan resolves the build plan without running any build or hook
+func (c *GobuildCommand) plan() (*BuildPlan, error) {
+	info := c.versionInfo
+	info.GoVersion = c.goVersion
+	p := &BuildPlan{
+		Workspace: c.Workspace,
+		Module:    c.module,
+		Version:   info,
+		Hooks: c.plannedHooks(c.Config.Go.Build.GlobalHooksDir, c.Config.Go.Build.Hooks,
+			c.hookCondition(c.platformStrings()...)),
+		Builds: []PlannedBuild{},
+	}
+	for _, task := range c.tasks() {
+		target := path.Join(c.module, task.target)
+		output, err := c.outputFile(task.platform, target)
+		if err != nil {
+			return nil, err
+		}
+		args, err := c.gobuildArgs(task, output, target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render go build args of target %s: %w", task.target, err)
+		}
+		p.Builds = append(p.Builds, PlannedBuild{
+			Target:   task.target,
+			Platform: task.platform.String(),
+			Output:   output,
+			Args:     args,
+			Env:      c.taskEnv(task),
+			Hooks: c.plannedHooks(path.Join(c.Workspace, task.target), task.config.Hooks,
+				c.hookCondition(task.platform.String()), task.platform.hookSuffixes()...),
+		})
+	}
+	return p, nil
+}
+
+// plannedHooks returns hook files found in dir and hook steps matching cond
+func (c *GobuildCommand) plannedHooks(dir string, steps config.GoBuildHooks, cond hook.Condition, suffixes ...string) PlannedHooks {
+	find := func(phase hook.Phase, steps []config.HookStep) []string {
+		hooks := []string{}
+		for _, f := range hook.Find(dir, phase, suffixes...) {
+			if rel, err := filepath.Rel(c.Workspace, f); err == nil {
+				f = rel
+			}
+			hooks = append(hooks, f)
+		}
+		for _, step := range steps {
+			if cond.Match(step.When) {
+				hooks = append(hooks, "step:"+hook.StepName(step))
+			}
+		}
+		return hooks
+	}
+	return PlannedHooks{
+		Pre:  find(hook.PreBuild, steps.Pre),
+		Post: find(hook.PostBuild, steps.Post),
+	}
+}
+
+// Write writes plan to w in format
+func (p *BuildPlan) Write(w io.Writer, format string) error {
+	if format == PlanFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p)
+	}
+
+	fmt.Fprintf(w, "Module:    %s\n", p.Module)
+	fmt.Fprintf(w, "Workspace: %s\n", p.Workspace)
+	fmt.Fprintf(w, "Version:   %s\n", p.Version.GitVersion)
+	fmt.Fprintf(w, "Builds:    %d\n", len(p.Builds))
+	writePlannedHooks(w, "", p.Hooks)
+	for _, b := range p.Builds {
+		fmt.Fprintf(w, "\n%s %s\n", b.Target, b.Platform)
+		fmt.Fprintf(w, "  output: %s\n", b.Output)
+		keys := make([]string, 0, len(b.Env))
+		for k := range b.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		env := []string{}
+		for _, k := range keys {
+			env = append(env, k+"="+b.Env[k])
+		}
+		fmt.Fprintf(w, "  env:    %s\n", strings.Join(env, " "))
+		fmt.Fprintf(w, "  args:   go %s\n", strings.Join(quoteArgs(b.Args), " "))
+		writePlannedHooks(w, "  ", b.Hooks)
+	}
+	return nil
+}
+
+func writePlannedHooks(w io.Writer, indent string, hooks PlannedHooks) {
+	none := func(list []string) string {
+		if len(list) == 0 {
+			return "-"
+		}
+		return strings.Join(list, ", ")
+	}
+	fmt.Fprintf(w, "%spre-build:  %s\n", indent, none(hooks.Pre))
+	fmt.Fprintf(w, "%spost-build: %s\n", indent, none(hooks.Post))
+}
+
+// quoteArgs quotes args containing spaces, so they can be copied into shell
+func quoteArgs(args []string) []string {
+	quoted := make([]string, 0, len(args))
+	for _, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\"'$") {
+			a = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+		quoted = append(quoted, a)
+	}
+	return quoted
+}
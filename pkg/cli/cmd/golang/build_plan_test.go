@@ -0,0 +1,168 @@
+package golang
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+func TestQuoteArgs(t *testing.T) {
+	got := quoteArgs([]string{"build", "-ldflags", "-X main.v=1 -s", "", "it's", "-o", "bin/foo"})
+	want := []string{"build", "-ldflags", "'-X main.v=1 -s'", "''", `'it'\''s'`, "-o", "bin/foo"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("quoteArgs() = %v, want %v", got, want)
+	}
+}
+
+// runPlan runs go build in dry run mode with fake go, and returns the plan
+// printed in format
+func runPlan(t *testing.T, cfg *config.Config, format string, args ...string) string {
+	t.Helper()
+	runner.SetDryRun(true)
+	defer runner.SetDryRun(false)
+
+	goCmd := fakeGo()
+	out := &bytes.Buffer{}
+	args = append([]string{"--version=v1.0.0", "--plan-format=" + format}, args...)
+	if err := execute(newTestGobuild("/ws", withConfig(cfg), withGo(goCmd)), out, args...); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range goCmd.Calls() {
+		if c.Args[0] == "build" {
+			t.Errorf("go build runs in dry run: %s", c)
+		}
+	}
+	return out.String()
+}
+
+func TestGobuildCommand_Plan(t *testing.T) {
+	// plannedBuild is the checked part of PlannedBuild, ldflags are
+	// substrings of the rendered -ldflags
+	type plannedBuild struct {
+		target   string
+		platform string
+		output   string
+		env      map[string]string
+		ldflags  []string
+	}
+	versionFlag := "-X github.com/zoumo/make-rules/version.gitVersion=v1.0.0"
+	tests := []struct {
+		name      string
+		overrides map[string]config.GoBuildTarget
+		args      []string
+		want      []plannedBuild
+	}{
+		{
+			name: "targets x platforms",
+			args: []string{"--platforms=linux/amd64,linux/arm/v7"},
+			want: []plannedBuild{
+				{"cmd/bar", "linux/amd64", "/ws/bin/linux_amd64/bar", map[string]string{"GOOS": "linux", "GOARCH": "amd64"}, []string{versionFlag}},
+				{"cmd/bar", "linux/arm/v7", "/ws/bin/linux_arm_v7/bar", map[string]string{"GOOS": "linux", "GOARCH": "arm", "GOARM": "7"}, []string{versionFlag}},
+				{"cmd/foo", "linux/amd64", "/ws/bin/linux_amd64/foo", map[string]string{"GOOS": "linux", "GOARCH": "amd64"}, []string{versionFlag}},
+				{"cmd/foo", "linux/arm/v7", "/ws/bin/linux_arm_v7/foo", map[string]string{"GOOS": "linux", "GOARCH": "arm", "GOARM": "7"}, []string{versionFlag}},
+			},
+		},
+		{
+			name: "target overrides",
+			overrides: map[string]config.GoBuildTarget{
+				"foo": {Platforms: []string{"darwin/arm64"}, Env: map[string]string{"FOO": "bar"}, LDFlags: []string{"-s"}},
+			},
+			want: []plannedBuild{
+				{"cmd/bar", "linux/amd64", "/ws/bin/linux_amd64/bar", map[string]string{"GOOS": "linux", "GOARCH": "amd64"}, []string{versionFlag}},
+				{"cmd/foo", "darwin/arm64", "/ws/bin/darwin_arm64/foo", map[string]string{"GOOS": "darwin", "GOARCH": "arm64", "FOO": "bar"}, []string{versionFlag, " -s"}},
+			},
+		},
+		{
+			name: "static",
+			args: []string{"--platforms=linux/amd64", "--static", "cmd/foo"},
+			want: []plannedBuild{
+				{"cmd/foo", "linux/amd64", "/ws/bin/linux_amd64/foo", map[string]string{"GOOS": "linux", "GOARCH": "amd64", "CGO_ENABLED": "0"}, []string{versionFlag}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.Go.Build.Platforms = []string{"linux/amd64"}
+			cfg.Go.Build.Targets.Overrides = tt.overrides
+			plan := BuildPlan{}
+			if err := json.Unmarshal([]byte(runPlan(t, cfg, PlanFormatJSON, tt.args...)), &plan); err != nil {
+				t.Fatal(err)
+			}
+			if plan.Module != "example.com/proj" || plan.Version.GitVersion != "v1.0.0" {
+				t.Errorf("plan module = %s, version = %s", plan.Module, plan.Version.GitVersion)
+			}
+			sort.Slice(plan.Builds, func(i, j int) bool {
+				return plan.Builds[i].Target+plan.Builds[i].Platform < plan.Builds[j].Target+plan.Builds[j].Platform
+			})
+			if len(plan.Builds) != len(tt.want) {
+				t.Fatalf("plan has %d builds, want %d: %+v", len(plan.Builds), len(tt.want), plan.Builds)
+			}
+			for i, want := range tt.want {
+				got := plan.Builds[i]
+				if got.Target != want.target || got.Platform != want.platform || got.Output != want.output {
+					t.Errorf("build %d = %s %s %s, want %s %s %s", i, got.Target, got.Platform, got.Output, want.target, want.platform, want.output)
+				}
+				delete(got.Env, "WORKSPACE")
+				if !reflect.DeepEqual(got.Env, want.env) {
+					t.Errorf("env of build %d = %v, want %v", i, got.Env, want.env)
+				}
+				// go build -ldflags <ldflags> -o <output> <package>
+				n := len(got.Args)
+				if n < 6 || got.Args[0] != "build" || got.Args[n-5] != "-ldflags" || got.Args[n-3] != "-o" || got.Args[n-2] != want.output || got.Args[n-1] != "example.com/proj/"+want.target {
+					t.Errorf("args of build %d = %v", i, got.Args)
+					continue
+				}
+				for _, f := range want.ldflags {
+					if !strings.Contains(got.Args[n-4], f) {
+						t.Errorf("ldflags of build %d = %q, want %q in it", i, got.Args[n-4], f)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGobuildCommand_PlanJSON(t *testing.T) {
+	cfg := config.New()
+	cfg.Go.Build.Platforms = []string{"linux/amd64"}
+	out := runPlan(t, cfg, PlanFormatJSON, "cmd/foo")
+
+	// keys of the JSON plan are the interface for CI
+	plan := map[string]any{}
+	if err := json.Unmarshal([]byte(out), &plan); err != nil {
+		t.Fatal(err)
+	}
+	keys := func(m any) []string {
+		ks := []string{}
+		for k := range m.(map[string]any) {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+		return ks
+	}
+	if got, want := keys(plan), []string{"builds", "hooks", "module", "version", "workspace"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plan keys = %v, want %v", got, want)
+	}
+	if got, want := keys(plan["hooks"]), []string{"post", "pre"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hooks keys = %v, want %v", got, want)
+	}
+	builds := plan["builds"].([]any)
+	if len(builds) != 1 {
+		t.Fatalf("plan has %d builds, want 1", len(builds))
+	}
+	if got, want := keys(builds[0]), []string{"args", "env", "hooks", "output", "platform", "target"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("build keys = %v, want %v", got, want)
+	}
+
+	text := runPlan(t, cfg, PlanFormatText, "cmd/foo")
+	if !strings.Contains(text, "cmd/foo linux/amd64\n  output: /ws/bin/linux_amd64/foo\n") || !strings.Contains(text, "Builds:    1\n") {
+		t.Errorf("text plan = %s", text)
+	}
+}
@@ -0,0 +1,78 @@
+package golang
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/zoumo/make-rules/version"
+)
+
+// BuildReport is the machine-readable manifest of artifacts built by go build
+type BuildReport struct {
+	Workspace string          `json:"workspace"`
+	Module    string          `json:"module"`
+	Artifacts []BuildArtifact `json:"artifacts"`
+
+	mu sync.Mutex
+}
+
+// BuildArtifact is the output of go build for one (target, platform)
+type BuildArtifact struct {
+	Target   string `json:"target"`
+	Platform string `json:"platform"`
+	Output   string `json:"output"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	// Duration is the seconds go build takes, it is 0 if the build is skipped
+	Duration float64 `json:"duration"`
+	// Cached is true if go build is skipped by build cache
+	Cached  bool              `json:"cached"`
+	Version version.Info      `json:"version"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	// SBOM are paths of SBOMs written for the artifact
+	SBOM []string `json:"sbom,omitempty"`
+}
+
+// Add adds artifact into report, size and sha256 are read from the output
+func (r *BuildReport) Add(artifact BuildArtifact) error {
+	info, err := os.Stat(artifact.Output)
+	if err != nil {
+		return err
+	}
+	digest, err := fileSHA256(artifact.Output)
+	if err != nil {
+		return err
+	}
+	artifact.Size = info.Size()
+	artifact.SHA256 = digest
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Artifacts = append(r.Artifacts, artifact)
+	return nil
+}
+
+// Write writes report to file as JSON, artifacts are sorted by target and
+// platform
+func (r *BuildReport) Write(file string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.Slice(r.Artifacts, func(i, j int) bool {
+		if r.Artifacts[i].Target != r.Artifacts[j].Target {
+			return r.Artifacts[i].Target < r.Artifacts[j].Target
+		}
+		return r.Artifacts[i].Platform < r.Artifacts[j].Platform
+	})
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
@@ -0,0 +1,469 @@
+package golang
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+// fakeGo returns a fake go of module example.com/proj with main packages
+// cmd/foo and cmd/bar, go build writes the output file
+func fakeGo() *runner.Fake {
+	return fakeGoWithMains("cmd/foo", "cmd/bar")
+}
+
+// fakeGoWithMains returns a fake go like fakeGo with main packages in dirs
+// mains relative to module root
+func fakeGoWithMains(mains ...string) *runner.Fake {
+	goCmd := fakeGoWithoutBuild(mains...)
+	goCmd.On("build", "...").Do(func(c runner.Call) error {
+		for i, arg := range c.Args {
+			if arg == "-o" && i+1 < len(c.Args) {
+				output := c.Args[i+1]
+				if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+					return err
+				}
+				return os.WriteFile(output, []byte(c.String()), 0755)
+			}
+		}
+		return nil
+	})
+	return goCmd
+}
+
+// fakeGoWithoutBuild returns a fake go like fakeGoWithMains without the rule
+// of go build, so tests can add their own
+func fakeGoWithoutBuild(mains ...string) *runner.Fake {
+	list := ""
+	for _, m := range mains {
+		list += "main " + path.Join("example.com/proj", m) + "\n"
+	}
+	list += "util example.com/proj/pkg/util\n"
+	goCmd := runner.NewFake("go")
+	goCmd.On("list", "-m").Return("example.com/proj\n", "", 0)
+	goCmd.On("list", "-e", "-f", "*", "./...").Return(list, "", 0)
+	goCmd.On("tool", "dist", "list", "-json").Return(`[
+		{"GOOS": "linux", "GOARCH": "amd64", "FirstClass": true},
+		{"GOOS": "linux", "GOARCH": "arm", "FirstClass": true},
+		{"GOOS": "darwin", "GOARCH": "arm64", "FirstClass": true}
+	]`, "", 0)
+	goCmd.On("env", "GOVERSION").Return("go1.99.0\n", "", 0)
+	goCmd.On("list", "-deps", "...").Return("", "", 0)
+	return goCmd
+}
+
+func TestGobuildCommand_Matrix(t *testing.T) {
+	workspace := t.TempDir()
+	goCmd := fakeGo()
+	args := []string{"--platforms=linux/amd64,linux/arm/v7,darwin/arm64", "--version=v1.0.0", "-j", "2"}
+	if err := runTestBuild(workspace, args, withGo(goCmd)); err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, c := range goCmd.Calls() {
+		if c.Args[0] != "build" {
+			continue
+		}
+		output := c.Args[len(c.Args)-2]
+		rel, _ := filepath.Rel(workspace, output)
+		got = append(got, strings.Join([]string{c.Env["GOOS"], c.Env["GOARCH"], c.Env["GOARM"], rel, c.Args[len(c.Args)-1]}, " "))
+	}
+	sort.Strings(got)
+	want := []string{
+		"darwin arm64  bin/darwin_arm64/bar example.com/proj/cmd/bar",
+		"darwin arm64  bin/darwin_arm64/foo example.com/proj/cmd/foo",
+		"linux amd64  bin/linux_amd64/bar example.com/proj/cmd/bar",
+		"linux amd64  bin/linux_amd64/foo example.com/proj/cmd/foo",
+		"linux arm 7 bin/linux_arm_v7/bar example.com/proj/cmd/bar",
+		"linux arm 7 bin/linux_arm_v7/foo example.com/proj/cmd/foo",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("go build matrix:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGobuildCommand_Validate_SameOutput(t *testing.T) {
+	cfg := config.New()
+	cfg.Go.Build.Targets.Include = []string{"cmd/*", "tools/..."}
+	err := runTestBuild(t.TempDir(), []string{"--platforms=linux/amd64"}, withConfig(cfg), withGo(fakeGoWithMains("cmd/foo", "tools/foo")))
+	if err == nil || !strings.Contains(err.Error(), "target cmd/foo") || !strings.Contains(err.Error(), "target tools/foo") {
+		t.Errorf("Execute() = %v, want error of the same output of cmd/foo and tools/foo", err)
+	}
+}
+
+func TestGobuildCommand_MergeFlags(t *testing.T) {
+	workspace := t.TempDir()
+	data := "go:\n  build:\n    platforms: [darwin/arm64]\n    tags: [netgo]\n    parallelism: 4\n" +
+		"    env:\n      A: config\n      C: \"3\"\n"
+	if err := os.WriteFile(filepath.Join(workspace, config.ConfigPath), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestGobuild(workspace)
+	completeTestBuild(t, c, "--platforms=linux/amd64,linux/arm/v7", "--build-env=A=1,B=2", "--static")
+
+	// flags set in command line take precedence over the config file, the
+	// others keep values of the config file
+	b := c.Config.Go.Build
+	if want := []string{"linux/amd64", "linux/arm/v7"}; !reflect.DeepEqual(b.Platforms, want) {
+		t.Errorf("platforms = %v, want %v", b.Platforms, want)
+	}
+	if want := map[string]string{"A": "1", "B": "2", "C": "3"}; !reflect.DeepEqual(b.Env, want) {
+		t.Errorf("env = %v, want %v", b.Env, want)
+	}
+	if !b.Static {
+		t.Error("static = false, want flag value")
+	}
+	if !reflect.DeepEqual(b.Tags, []string{"netgo"}) || b.Parallelism != 4 {
+		t.Errorf("tags = %v, parallelism = %d, want values of config file", b.Tags, b.Parallelism)
+	}
+	if b.CGO != nil {
+		t.Errorf("cgo = %v, want unset", *b.CGO)
+	}
+}
+
+func TestGobuildCommand_RootTarget(t *testing.T) {
+	workspace := t.TempDir()
+	goCmd := fakeGoWithMains(".")
+	// the main package in module root is opt-in
+	cfg := config.New()
+	cfg.Go.Build.Targets.Include = []string{".", "cmd/*"}
+	if err := runTestBuild(workspace, []string{"--platforms=linux/amd64"}, withConfig(cfg), withGo(goCmd)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "bin/linux_amd64/proj")); err != nil {
+		t.Errorf("binary of main package in module root is not built: %v", err)
+	}
+}
+
+func TestGobuildCommand_Hooks(t *testing.T) {
+	cosign := runner.NewFake("cosign")
+	cosign.On("sign", "*")
+	cfg := config.New()
+	cfg.Go.Build.Hooks.Post = []config.HookStep{{Command: "cosign", Args: []string{"sign", "${MAKE_RULES_GO_BUILD_PLATFORMS}"}}}
+	err := runTestBuild(t.TempDir(), []string{"--platforms=linux/amd64,darwin/arm64"},
+		withConfig(cfg), withHookExecutor(func(name string) runner.Executor { return cosign }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := cosign.Calls()
+	if len(calls) != 1 || calls[0].String() != "cosign sign linux/amd64,darwin/arm64" {
+		t.Errorf("hook calls = %v, want cosign sign of all platforms", calls)
+	}
+}
+
+func TestGobuildCommand_HookBinaryDirs(t *testing.T) {
+	tests := []struct {
+		output string
+		want   []string
+	}{
+		// dirs are shared by targets
+		{"", []string{"bin/darwin_arm64", "bin/linux_amd64"}},
+		// name in a dir of output
+		{"{{.Workspace}}/bin/{{.Name}}/{{.OS}}_{{.Arch}}/{{.Name}}{{.Ext}}",
+			[]string{"bin/bar/darwin_arm64", "bin/bar/linux_amd64", "bin/foo/darwin_arm64", "bin/foo/linux_amd64"}},
+	}
+	for _, tt := range tests {
+		workspace := t.TempDir()
+		hooks := runner.NewFake("echo")
+		hooks.On("*")
+		cfg := config.New()
+		if tt.output != "" {
+			cfg.Go.Build.Output = tt.output
+		}
+		cfg.Go.Build.Hooks.Post = []config.HookStep{{Command: "echo", Args: []string{"${MAKE_RULES_GO_BUILD_BINARY_DIRS}"}}}
+		err := runTestBuild(workspace, []string{"--platforms=linux/amd64,darwin/arm64"},
+			withConfig(cfg), withHookExecutor(func(name string) runner.Executor { return hooks }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := hooks.Calls()
+		if len(calls) != 1 {
+			t.Fatalf("hook calls = %v, want one", calls)
+		}
+		got := []string{}
+		for _, dir := range strings.Split(calls[0].Args[0], ",") {
+			rel, _ := filepath.Rel(workspace, dir)
+			got = append(got, rel)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("binary dirs of output %q = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
+
+// writeHookFiles writes executable hook files with a shebang in dir
+func writeHookFiles(t *testing.T, dir, content string, names ...string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGobuildCommand_TargetHooks(t *testing.T) {
+	workspace := t.TempDir()
+	hookDir := filepath.Join(workspace, "cmd/foo")
+	// written out of order, they run from the least to the most specific
+	writeHookFiles(t, hookDir, "#!/bin/sh\n",
+		"post-build.linux", "pre-build.linux_arm_v7", "pre-build.linux_amd64", "pre-build.linux", "pre-build", "pre-build.darwin")
+	hooks := runner.NewFake("hook")
+	hooks.On("...")
+	err := runTestBuild(workspace, []string{"--platforms=linux/amd64,linux/arm/v7", "cmd/foo"},
+		withHookExecutor(func(name string) runner.Executor { return hooks.WithEnvs("MAKE_RULES_TEST_HOOK", filepath.Base(name)) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string][]string{}
+	for _, c := range hooks.Calls() {
+		platform := c.Env["MAKE_RULES_PLATFORM"]
+		got[platform] = append(got[platform], c.Env["MAKE_RULES_TEST_HOOK"])
+		want := map[string]string{
+			"MAKE_RULES_TARGET":   "cmd/foo",
+			"MAKE_RULES_GOOS":     "linux",
+			"MAKE_RULES_GOARCH":   map[string]string{"linux/amd64": "amd64", "linux/arm/v7": "arm"}[platform],
+			"MAKE_RULES_VARIANT":  map[string]string{"linux/amd64": "", "linux/arm/v7": "v7"}[platform],
+			"MAKE_RULES_OUTPUT":   filepath.Join(workspace, "bin", strings.ReplaceAll(platform, "/", "_"), "foo"),
+			"MAKE_RULES_PLATFORM": platform,
+		}
+		for k, v := range want {
+			if c.Env[k] != v {
+				t.Errorf("env %s of hook %s for %s = %q, want %q", k, c.Env["MAKE_RULES_TEST_HOOK"], platform, c.Env[k], v)
+			}
+		}
+	}
+	want := map[string][]string{
+		"linux/amd64":  {"pre-build", "pre-build.linux", "pre-build.linux_amd64", "post-build.linux"},
+		"linux/arm/v7": {"pre-build", "pre-build.linux", "pre-build.linux_arm_v7", "post-build.linux"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("hooks = %v, want %v", got, want)
+	}
+}
+
+func TestGobuildCommand_HookTimeout(t *testing.T) {
+	workspace := t.TempDir()
+	writeHookFiles(t, filepath.Join(workspace, "cmd/foo"), "#!/bin/sh\nexec sleep 10\n", "pre-build")
+	cfg := config.New()
+	cfg.Go.Build.HookTimeout = "100ms"
+	goCmd := fakeGo()
+	start := time.Now()
+	err := runTestBuild(workspace, []string{"--platforms=linux/amd64", "cmd/foo"}, withConfig(cfg), withGo(goCmd))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Execute() = %v, want error of hook timeout", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("build returned after %v, the hook is not killed on timeout", d)
+	}
+	for _, c := range goCmd.Calls() {
+		if c.Args[0] == "build" {
+			t.Errorf("go build runs after pre-build hook failed: %s", c)
+		}
+	}
+}
+
+func TestOutputCommands_Flags(t *testing.T) {
+	// commands using binaries of go build do not bind flags of go build
+	// itself, e.g. --jobs, --sbom
+	for _, cmd := range []*cobra.Command{NewGoInstallCommand(), NewGoPackageCommand(), NewGoSBOMCommand()} {
+		for _, name := range []string{"platforms", "version", "profile"} {
+			if cmd.Flags().Lookup(name) == nil {
+				t.Errorf("go %s has no flag --%s", cmd.Name(), name)
+			}
+		}
+		for _, name := range []string{"jobs", "sbom", "plan-format", "force", "report"} {
+			if cmd.Flags().Lookup(name) != nil {
+				t.Errorf("go %s has flag --%s of go build", cmd.Name(), name)
+			}
+		}
+	}
+}
+
+// recordLogger records "msg target" of every log
+type recordLogger struct {
+	mu     *sync.Mutex
+	logs   *[]string
+	target string
+}
+
+func newRecordLogger() *recordLogger {
+	return &recordLogger{mu: &sync.Mutex{}, logs: &[]string{}}
+}
+
+func (l *recordLogger) Enabled() bool { return true }
+
+func (l *recordLogger) Info(msg string, keysAndValues ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.logs = append(*l.logs, msg+" "+l.target)
+}
+
+func (l *recordLogger) Error(err error, msg string, keysAndValues ...any) {
+	l.Info(msg, keysAndValues...)
+}
+
+func (l *recordLogger) V(level int) log.Logger { return l }
+
+func (l *recordLogger) WithValues(keysAndValues ...any) log.Logger {
+	ll := *l
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == "target" {
+			ll.target = keysAndValues[i+1].(string)
+		}
+	}
+	return &ll
+}
+
+func (l *recordLogger) WithName(name string) log.Logger { return l }
+
+func TestGobuildCommand_ParallelOutput(t *testing.T) {
+	goCmd := fakeGoWithoutBuild("cmd/foo", "cmd/bar")
+	// both builds print output after they are all started, their logs are
+	// interleaved if they are not buffered
+	var started sync.WaitGroup
+	started.Add(2)
+	goCmd.On("build", "...").Return("line 1\nline 2\n", "", 0).Do(func(c runner.Call) error {
+		started.Done()
+		started.Wait()
+		output := c.Args[len(c.Args)-2]
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(output, []byte(c.String()), 0755)
+	})
+	c := newTestGobuild(t.TempDir(), withGo(goCmd))
+	cmd := completeTestBuild(t, c, "--platforms=linux/amd64", "-j", "2")
+	logger := newRecordLogger()
+	c.Logger = logger
+	if err := c.Run(cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// logs of every target are grouped, except progress lines logged when
+	// tasks start
+	targets := []string{}
+	progress := 0
+	for _, l := range *logger.logs {
+		if strings.HasPrefix(l, taskStartedMsg+" ") {
+			progress++
+			continue
+		}
+		target := l[strings.LastIndex(l, " ")+1:]
+		if target != "" && (len(targets) == 0 || targets[len(targets)-1] != target) {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) != 2 {
+		t.Errorf("logs of targets are interleaved:\n%s", strings.Join(*logger.logs, "\n"))
+	}
+	if progress != 2 {
+		t.Errorf("%d progress lines are logged, want one for each task:\n%s", progress, strings.Join(*logger.logs, "\n"))
+	}
+}
+
+func TestGobuildCommand_ParallelFailure(t *testing.T) {
+	goCmd := fakeGoWithoutBuild("cmd/foo", "cmd/bar")
+	goCmd.On("build", "...").Return("", "compile error", 1)
+	args := []string{"--platforms=linux/amd64,linux/arm/v7,darwin/arm64", "-j", "2"}
+	if err := runTestBuild(t.TempDir(), args, withGo(goCmd)); err == nil {
+		t.Fatal("Execute() = nil, want error of go build")
+	}
+	// the first failure cancels tasks not started yet
+	builds := 0
+	for _, c := range goCmd.Calls() {
+		if c.Args[0] == "build" {
+			builds++
+		}
+	}
+	if builds > 2 {
+		t.Errorf("%d of 6 builds run after the first failure with 2 jobs", builds)
+	}
+}
+
+func TestGobuildCommand_Report(t *testing.T) {
+	workspace := t.TempDir()
+	t.Setenv("MAKE_RULES_TEST_UNRELATED", "1")
+	readReport := func() BuildArtifact {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(workspace, "report.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := BuildReport{}
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Module != "example.com/proj" || len(report.Artifacts) != 1 {
+			t.Fatalf("report has module %s and %d artifacts, want one artifact of example.com/proj", report.Module, len(report.Artifacts))
+		}
+		return report.Artifacts[0]
+	}
+	args := []string{"--report=report.json", "--build-env=FOO=bar", "--platforms=linux/amd64", "cmd/foo"}
+
+	goCmd := fakeGo()
+	if err := runTestBuild(workspace, args, withGo(goCmd)); err != nil {
+		t.Fatal(err)
+	}
+	artifact := readReport()
+	output := filepath.Join(workspace, "bin/linux_amd64/foo")
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := fileSHA256(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artifact.Target != "cmd/foo" || artifact.Platform != "linux/amd64" || artifact.Output != output {
+		t.Errorf("artifact = %s %s %s", artifact.Target, artifact.Platform, artifact.Output)
+	}
+	if artifact.Size != info.Size() || artifact.SHA256 != digest {
+		t.Errorf("artifact size = %d, sha256 = %s, want %d, %s", artifact.Size, artifact.SHA256, info.Size(), digest)
+	}
+	if artifact.Cached {
+		t.Error("artifact of the first build is cached")
+	}
+	if build := lastBuild(t, goCmd); !reflect.DeepEqual(artifact.Args, build.Args) {
+		t.Errorf("artifact args = %v, want %v", artifact.Args, build.Args)
+	}
+	// env holds only keys affecting outputs and extra build env
+	allowed := map[string]bool{"FOO": true}
+	for _, k := range cacheEnvKeys {
+		allowed[k] = true
+	}
+	for k := range artifact.Env {
+		if !allowed[k] {
+			t.Errorf("artifact env has unrelated key %s", k)
+		}
+	}
+	if artifact.Env["FOO"] != "bar" || artifact.Env["GOOS"] != "linux" || artifact.Env["GOARCH"] != "amd64" {
+		t.Errorf("artifact env = %v, want FOO, GOOS and GOARCH", artifact.Env)
+	}
+
+	// the second build is skipped by cache
+	if err := runTestBuild(workspace, args); err != nil {
+		t.Fatal(err)
+	}
+	if cached := readReport(); !cached.Cached || cached.SHA256 != digest {
+		t.Errorf("artifact of the second build = %+v, want cached with the same sha256", cached)
+	}
+}
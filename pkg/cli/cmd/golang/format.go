@@ -3,6 +3,7 @@ package golang
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"io/ioutil"
 	"os"
 	"path"
@@ -124,8 +125,8 @@ var _ cli.ComplexOptions = &FormatCommand{}
 
 type FormatCommand struct {
 	*common.CommonOptions
-	goCmd        *runner.Runner
-	goimportsCmd *runner.Runner
+	goCmd        runner.Executor
+	goimportsCmd runner.Executor
 
 	module string
 }
@@ -151,7 +152,7 @@ func (c *FormatCommand) Complete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	// find module
-	out, err := c.goCmd.RunOutput("list", "-m")
+	out, err := c.goCmd.ReadOnly().RunOutput("list", "-m")
 	if err != nil {
 		c.Logger.Error(err, string(out))
 		return err
@@ -168,6 +169,13 @@ func (c *FormatCommand) Validate() error {
 }
 
 func (c *FormatCommand) Run(cmd *cobra.Command, args []string) error {
+	env := map[string]string{"MAKE_RULES_MODULE": c.module}
+	return c.RunWithHooks(context.Background(), "format", env, func() error {
+		return c.run(args)
+	})
+}
+
+func (c *FormatCommand) run(args []string) error {
 	if len(args) > 0 {
 		// format used defined targets
 		for _, f := range args {
@@ -217,8 +225,11 @@ func (c *FormatCommand) Run(cmd *cobra.Command, args []string) error {
 
 func (c *FormatCommand) format(finename string) error {
 	// format
-	// delete empty line between import ( and )
-	if err := deleteEmptyLineWithinImports(finename); err != nil {
+	// delete empty line between import ( and ), goimports only logs its
+	// command in dry run mode
+	if runner.DryRun() {
+		c.Logger.Info("Dry run, file is not changed", "file", finename)
+	} else if err := deleteEmptyLineWithinImports(finename); err != nil {
 		c.Logger.Error(err, "failed to delete empty line with in import()")
 		return err
 	}
@@ -0,0 +1,114 @@
+package golang
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zoumo/golib/cli"
+
+	"github.com/zoumo/make-rules/pkg/cli/common"
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+// testBuildOptions are options of GobuildCommand created by newTestGobuild
+type testBuildOptions struct {
+	config       *config.Config
+	goCmd        *runner.Fake
+	docker       *runner.Fake
+	now          func() time.Time
+	hookExecutor func(name string) runner.Executor
+}
+
+type testBuildOption func(*testBuildOptions)
+
+// withConfig sets the config, it defaults to config.New()
+func withConfig(cfg *config.Config) testBuildOption {
+	return func(o *testBuildOptions) { o.config = cfg }
+}
+
+// withGo sets the fake go, it defaults to fakeGo()
+func withGo(goCmd *runner.Fake) testBuildOption {
+	return func(o *testBuildOptions) { o.goCmd = goCmd }
+}
+
+// withDocker sets the fake docker, it defaults to a fake without any rule
+func withDocker(docker *runner.Fake) testBuildOption {
+	return func(o *testBuildOptions) { o.docker = docker }
+}
+
+// withClock sets the clock of build date
+func withClock(now func() time.Time) testBuildOption {
+	return func(o *testBuildOptions) { o.now = now }
+}
+
+// withHookExecutor sets the executor of commands run by hooks
+func withHookExecutor(fn func(name string) runner.Executor) testBuildOption {
+	return func(o *testBuildOptions) { o.hookExecutor = fn }
+}
+
+// newTestCommonOptions returns CommonOptions of workspace with cfg
+func newTestCommonOptions(workspace string, cfg *config.Config) *common.CommonOptions {
+	return &common.CommonOptions{
+		CommonOptions: &cli.CommonOptions{Workspace: workspace},
+		Config:        cfg,
+	}
+}
+
+// newTestGobuild returns GobuildCommand of workspace running fake go and
+// docker
+func newTestGobuild(workspace string, opts ...testBuildOption) *GobuildCommand {
+	o := &testBuildOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.config == nil {
+		o.config = config.New()
+	}
+	if o.goCmd == nil {
+		o.goCmd = fakeGo()
+	}
+	if o.docker == nil {
+		o.docker = runner.NewFake("docker")
+	}
+	c := newGobuildCommand()
+	c.CommonOptions = newTestCommonOptions(workspace, o.config)
+	c.HookExecutor = o.hookExecutor
+	c.goCmd = o.goCmd
+	c.dockerCmd = o.docker
+	c.now = o.now
+	return c
+}
+
+// execute runs command with args like the command line, its output is
+// written to out
+func execute(command cli.Command, out io.Writer, args ...string) error {
+	cmd := cli.NewCobraCommand(command)
+	if out != nil {
+		cmd.SetOut(out)
+	}
+	cmd.SetArgs(args)
+	return cmd.Execute()
+}
+
+// runTestBuild runs go build in workspace with args
+func runTestBuild(workspace string, args []string, opts ...testBuildOption) error {
+	return execute(newTestGobuild(workspace, opts...), nil, args...)
+}
+
+// completeTestBuild binds flags of c, parses args and completes c, so tests
+// can inspect or replace its fields before Run
+func completeTestBuild(t *testing.T, c *GobuildCommand, args ...string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{Use: "build"}
+	c.BindFlags(cmd.Flags())
+	if err := cmd.Flags().Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Complete(cmd, cmd.Flags().Args()); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
@@ -1,32 +1,40 @@
 package golang
 
 import (
+	"context"
 	"errors"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/zoumo/golib/cli"
 
-	"github.com/zoumo/make-rules/pkg/cli/common"
 	"github.com/zoumo/make-rules/pkg/runner"
 )
 
 var _ cli.Command = &GoinstallCommand{}
 
+// GoinstallCommand builds targets for the local platform if they are not up
+// to date, and installs them into bindir
 type GoinstallCommand struct {
 	*GobuildCommand
+
+	// prefix is the install prefix, binaries are installed into prefix/bin
+	// if bindir is not set
+	prefix string
+	bindir string
+	// symlink links binaries into bindir instead of copying them
+	symlink bool
 }
 
 func NewGoInstallCommand() *cobra.Command {
 	return cli.NewCobraCommand(&GoinstallCommand{
-		GobuildCommand: &GobuildCommand{
-			CommonOptions: common.NewCommonOptions(),
-			goCmd:         runner.NewRunner("go"),
-			bashCmd:       runner.NewRunner("bash"),
-		},
+		GobuildCommand: newGobuildCommand(),
 	})
 }
 
@@ -34,58 +42,190 @@ func (c *GoinstallCommand) Name() string {
 	return "install"
 }
 
-func (c *GoinstallCommand) Run(cmd *cobra.Command, args []string) error {
-	gobin := c.getGobinPath()
-	if len(gobin) == 0 {
-		return errors.New("failed to find GOBIN path, please set GOBIN or GOPATH in env")
+func (c *GoinstallCommand) BindFlags(fs *pflag.FlagSet) {
+	c.GobuildCommand.bindOutputFlags(fs)
+	fs.StringVar(&c.prefix, "prefix", c.prefix, "install binaries into <prefix>/bin")
+	fs.StringVar(&c.bindir, "bindir", c.bindir, "install binaries into the dir, defaults to <prefix>/bin, GOBIN or GOPATH/bin")
+	fs.BoolVar(&c.symlink, "symlink", c.symlink, "link binaries into bindir instead of copying them")
+}
+
+func (c *GoinstallCommand) Complete(cmd *cobra.Command, args []string) error {
+	if err := c.GobuildCommand.Complete(cmd, args); err != nil {
+		return err
+	}
+	switch {
+	case c.bindir != "":
+	case c.prefix != "":
+		c.bindir = filepath.Join(c.prefix, "bin")
+	default:
+		c.bindir = c.getGobinPath()
+	}
+	if c.bindir == "" {
+		return errors.New("failed to find GOBIN path, please set GOBIN or GOPATH in env, or use --bindir")
 	}
+	bindir, err := filepath.Abs(c.bindir)
+	if err != nil {
+		return err
+	}
+	c.bindir = bindir
+	return nil
+}
 
-	local := platform{
-		GOOS:   runtime.GOOS,
-		GOARCH: runtime.GOARCH,
+func (c *GoinstallCommand) Run(cmd *cobra.Command, args []string) error {
+	env := map[string]string{
+		"MAKE_RULES_MODULE":  c.module,
+		"MAKE_RULES_VERSION": c.versionInfo.GitVersion,
+		"MAKE_RULES_GOBIN":   c.bindir,
 	}
+	return c.RunWithHooks(context.Background(), "install", env, func() error {
+		return c.install(context.Background())
+	})
+}
 
-	found := false
-	for _, p := range c.platforms {
-		if local == p {
-			found = true
-			break
+// install builds targets for local platform, and installs them into bindir
+func (c *GoinstallCommand) install(ctx context.Context) error {
+	if len(c.targets) == 0 {
+		return nil
+	}
+	tasks := c.localTasks()
+	// hooks and go build only see the local platforms
+	c.platforms = nil
+	for _, task := range tasks {
+		if !containsPlatform(c.platforms, task.platform) {
+			c.platforms = append(c.platforms, task.platform)
 		}
 	}
-	if !found {
-		// skip
-		c.Logger.Info("skip copying binaries, no output built for local platform")
+	if err := c.initGoVersion(); err != nil {
+		return err
+	}
+	if err := c.buildTasks(ctx, tasks); err != nil {
+		return err
+	}
+	if runner.DryRun() {
+		for _, task := range tasks {
+			output, err := c.outputFile(task.platform, task.target)
+			if err != nil {
+				return err
+			}
+			c.Logger.Info("Dry run, binary is not installed", "target", task.target, "from", output,
+				"to", filepath.Join(c.bindir, filepath.Base(output)), "symlink", c.symlink)
+		}
 		return nil
 	}
 
-	for _, target := range c.targets {
-		outputFile := c.outputFile(local, target)
-		data, err := ioutil.ReadFile(outputFile)
+	if err := os.MkdirAll(c.bindir, 0755); err != nil {
+		return err
+	}
+	manifestFile := path.Join(c.Workspace, "bin", InstallManifestFile)
+	manifest, err := loadInstallManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		output, err := c.outputFile(task.platform, task.target)
 		if err != nil {
 			return err
 		}
-		targetFile := path.Join(gobin, path.Base(outputFile))
-
-		c.Logger.Info("copying", "from", outputFile, "to", targetFile)
-		err = ioutil.WriteFile(targetFile, data, 0755)
+		file := filepath.Join(c.bindir, filepath.Base(output))
+		c.Logger.Info("Go install", "target", task.target, "from", output, "to", file, "symlink", c.symlink)
+		installed := InstalledFile{Target: task.target, Path: file, Source: output, Symlink: c.symlink}
+		if c.symlink {
+			err = installSymlink(output, file)
+		} else {
+			installed.SHA256, err = installFile(output, file)
+		}
 		if err != nil {
+			c.Logger.Error(err, "failed to install binary", "target", task.target)
 			return err
 		}
+		manifest.Add(installed)
 	}
-	return nil
+	return manifest.Save(manifestFile)
 }
 
-// find gobin from env GOBIN or GOPATH/bin
+// localTasks returns builds of targets for the local platform. If a target
+// has platforms of local os and arch, the first one is used whatever its
+// variant is, otherwise the target is built for the local os and arch.
+func (c *GoinstallCommand) localTasks() []buildTask {
+	tasks := []buildTask{}
+	for _, target := range c.targets {
+		local, ok := localPlatform(c.targetPlatforms(target))
+		if !ok {
+			local = platform{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH}
+			c.Logger.V(1).Info("local platform is not configured for target, build it anyway", "target", target, "platform", local.String())
+		}
+		tasks = append(tasks, buildTask{target: target, platform: local, config: c.buildConfig(target)})
+	}
+	return tasks
+}
+
+// find gobin from env GOBIN or GOPATH/bin, env is resolved from
+// make-rules.yaml, dotenv files and --env like the env of go
 // if GOBIN and GOPATH is not set, return ""
 func (c *GoinstallCommand) getGobinPath() string {
-	gobin := os.Getenv("GOBIN")
-	if len(gobin) != 0 {
+	if gobin, _ := c.Env.Get("GOBIN"); len(gobin) != 0 {
 		return gobin
 	}
 
-	gopath := os.Getenv("GOPATH")
-	if len(gopath) != 0 {
-		return path.Join(gopath, "bin")
+	if gopath, _ := c.Env.Get("GOPATH"); len(gopath) != 0 {
+		// go installs into the first entry of GOPATH
+		return filepath.Join(filepath.SplitList(gopath)[0], "bin")
 	}
 	return ""
 }
+
+// localPlatform returns the first platform of local os and arch in
+// platforms, the variant is not checked
+func localPlatform(platforms []platform) (platform, bool) {
+	for _, p := range platforms {
+		if p.GOOS == runtime.GOOS && p.GOARCH == runtime.GOARCH {
+			return p, true
+		}
+	}
+	return platform{}, false
+}
+
+// installFile copies src to dst atomically, it writes a temp file in the dir of
+// dst and renames it to dst, so a running dst is never truncated. The sha256
+// of dst is returned.
+func installFile(src, dst string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", err
+	}
+	return fileSHA256(dst)
+}
+
+// installSymlink links dst to src atomically by renaming a temp link to dst
+func installSymlink(src, dst string) error {
+	tmp := fmt.Sprintf("%s.tmp%d", filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)), os.Getpid())
+	os.Remove(tmp)
+	if err := os.Symlink(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
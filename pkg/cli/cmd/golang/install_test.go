@@ -0,0 +1,51 @@
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	"github.com/zoumo/make-rules/pkg/environ"
+)
+
+// setFlagEnv sets the global --env flag to env until the test ends
+func setFlagEnv(t *testing.T, env ...string) {
+	t.Helper()
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	environ.InitFlags(fs)
+	args := []string{}
+	for _, kv := range env {
+		args = append(args, "--env", kv)
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		fs.Lookup("env").Value.(pflag.SliceValue).Replace(nil) //nolint:errcheck
+	})
+}
+
+func TestGoinstallCommand_GobinFromEnv(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("fake go only supports linux/amd64 of local platforms")
+	}
+	workspace := t.TempDir()
+	gobin := t.TempDir()
+	// --env takes precedence over the process env
+	t.Setenv("GOBIN", t.TempDir())
+	setFlagEnv(t, "GOBIN="+gobin)
+
+	c := &GoinstallCommand{GobuildCommand: newTestGobuild(workspace)}
+	if err := execute(c, nil, "cmd/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if c.bindir != gobin {
+		t.Errorf("bindir = %s, want GOBIN of --env %s", c.bindir, gobin)
+	}
+	if _, err := os.Stat(filepath.Join(gobin, "foo")); err != nil {
+		t.Errorf("binary is not installed into GOBIN of --env: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/zoumo/make-rules/version"
+)
+
+// VersionVariables are the version variables injected by -X in order
+var VersionVariables = []string{
+	"buildDate",
+	"gitVersion",
+	"gitCommit",
+	"gitRemote",
+	"gitTreeState",
+	"buildProfile",
+}
+
+func versionVariableValue(info version.Info, name string) string {
+	switch name {
+	case "buildDate":
+		return info.BuildDate
+	case "gitVersion":
+		return info.GitVersion
+	case "gitCommit":
+		return info.GitCommit
+	case "gitRemote":
+		return info.GitRemote
+	case "gitTreeState":
+		return info.GitTreeState
+	case "buildProfile":
+		return info.BuildProfile
+	}
+	return ""
+}
+
+// versionSymbol returns the full symbol of version variable name, it returns
+// "" if the variable is disabled
+func versionSymbol(pkg string, mapping map[string]string, name string) string {
+	symbol, ok := mapping[name]
+	if !ok {
+		symbol = name
+	}
+	if symbol == "" {
+		return ""
+	}
+	if strings.Contains(symbol, ".") {
+		return symbol
+	}
+	return pkg + "." + symbol
+}
+
+func validateVersionVariables(mapping map[string]string) error {
+	for name := range mapping {
+		found := false
+		for _, v := range VersionVariables {
+			if v == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown version variable %q, it must be one of %v", name, VersionVariables)
+		}
+	}
+	return nil
+}
+
+func parseLDFlags(ldflags []string) ([]*template.Template, error) {
+	tmpls := make([]*template.Template, 0, len(ldflags))
+	for _, f := range ldflags {
+		tmpl, err := template.New("ldflags").Option("missingkey=error").Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ldflags %q: %w", f, err)
+		}
+		tmpls = append(tmpls, tmpl)
+	}
+	return tmpls, nil
+}
+
+// taskVersionInfo returns the version info embedded in binary of task
+func (c *GobuildCommand) taskVersionInfo(task buildTask) version.Info {
+	info := c.versionInfo
+	info.GoVersion = c.goVersion
+	info.Compiler = "gc"
+	info.Platform = task.platform.String()
+	return info
+}
+
+// ldflags returns -ldflags of task. It injects version variables into
+// versionPackage and renders every ldflags template with version info of
+// the task.
+func (c *GobuildCommand) ldflags(task buildTask) (string, error) {
+	cfg := task.config
+	info := c.taskVersionInfo(task)
+
+	flags := []string{}
+	for _, name := range VersionVariables {
+		symbol := versionSymbol(cfg.VersionPackage, cfg.VersionVariables, name)
+		if symbol == "" {
+			continue
+		}
+		if name == "buildProfile" && info.BuildProfile == "" {
+			// only builds of a profile record it
+			continue
+		}
+		flags = append(flags, fmt.Sprintf("-X %s=%s", symbol, versionVariableValue(info, name)))
+	}
+
+	if cfg.Reproducible {
+		flags = append(flags, "-buildid=")
+	}
+	if cfg.Static && cfg.CGOEnabled() == "1" {
+		// cgo is enabled, link statically by external linker
+		flags = append(flags, `-linkmode external -extldflags "-static"`)
+	}
+
+	tmpls, err := parseLDFlags(cfg.LDFlags)
+	if err != nil {
+		return "", err
+	}
+	for _, tmpl := range tmpls {
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, info); err != nil {
+			return "", err
+		}
+		flags = append(flags, buf.String())
+	}
+
+	return strings.Join(flags, " "), nil
+}
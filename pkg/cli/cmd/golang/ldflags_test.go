@@ -0,0 +1,69 @@
+package golang
+
+import (
+	"testing"
+
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/version"
+)
+
+func TestGobuildCommand_ldflags(t *testing.T) {
+	c := &GobuildCommand{
+		versionInfo: version.Info{
+			GitVersion:   "v1.0.0",
+			GitCommit:    "abc",
+			GitTreeState: "clean",
+			GitRemote:    "origin",
+			BuildDate:    "2020-01-01T00:00:00Z",
+		},
+		goVersion: "go1.23.0",
+	}
+	tests := []struct {
+		name   string
+		config config.GoBuild
+		want   string
+	}{
+		{
+			"default package",
+			config.GoBuild{VersionPackage: "example.com/foo/version"},
+			"-X example.com/foo/version.buildDate=2020-01-01T00:00:00Z -X example.com/foo/version.gitVersion=v1.0.0 " +
+				"-X example.com/foo/version.gitCommit=abc -X example.com/foo/version.gitRemote=origin -X example.com/foo/version.gitTreeState=clean",
+		},
+		{
+			"mapping and templates",
+			config.GoBuild{
+				VersionPackage: "example.com/foo/version",
+				VersionVariables: map[string]string{
+					"buildDate":    "date",
+					"gitVersion":   "main.version",
+					"gitCommit":    "",
+					"gitRemote":    "",
+					"gitTreeState": "",
+				},
+				LDFlags: []string{"-s -w", "-X main.commit={{.GitCommit}}", "-X main.platform={{.Platform}}"},
+			},
+			"-X example.com/foo/version.date=2020-01-01T00:00:00Z -X main.version=v1.0.0 -s -w -X main.commit=abc -X main.platform=linux/arm64",
+		},
+	}
+	profiled := *c
+	profiled.versionInfo.BuildProfile = "release"
+	task := buildTask{target: "cmd/foo", platform: platform{GOOS: "linux", GOARCH: "arm64"}, config: config.GoBuild{
+		VersionPackage:   "example.com/foo/version",
+		VersionVariables: map[string]string{"buildDate": "", "gitVersion": "", "gitCommit": "", "gitRemote": "", "gitTreeState": ""},
+	}}
+	if got, _ := profiled.ldflags(task); got != "-X example.com/foo/version.buildProfile=release" {
+		t.Errorf("ldflags() of profile = %v", got)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := buildTask{target: "cmd/foo", platform: platform{GOOS: "linux", GOARCH: "arm64"}, config: tt.config}
+			got, err := c.ldflags(task)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ldflags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package golang
 
 import (
+	"context"
 	"fmt"
 	"path"
 
@@ -10,6 +11,7 @@ import (
 
 	"github.com/zoumo/make-rules/pkg/cli/common"
 	"github.com/zoumo/make-rules/pkg/golang"
+	"github.com/zoumo/make-rules/pkg/runner"
 )
 
 var _ cli.Command = &ModReplaceCommand{}
@@ -17,12 +19,14 @@ var _ cli.ComplexOptions = &ModReplaceCommand{}
 
 type ModReplaceCommand struct {
 	*common.CommonOptions
+	goCmd runner.Executor
 	gomod *golang.GomodHelper
 }
 
 func NewModReplaceCommand() *cobra.Command {
 	return cli.NewCobraCommand(&ModReplaceCommand{
 		CommonOptions: common.NewCommonOptions(),
+		goCmd:         runner.NewRunner("go"),
 	})
 }
 
@@ -39,7 +43,7 @@ func (c *ModReplaceCommand) Complete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	modfile := path.Join(c.Workspace, "go.mod")
-	c.gomod = golang.NewGomodHelper(modfile, c.Logger)
+	c.gomod = golang.NewGomodHelperWithExecutor(modfile, c.Logger, c.goCmd)
 	return nil
 }
 
@@ -61,5 +65,7 @@ func (c *ModReplaceCommand) Run(cmd *cobra.Command, args []string) error {
 		newPath = args[1]
 		version = args[2]
 	}
-	return c.gomod.Replace(path, newPath, version)
+	return c.RunWithHooks(context.Background(), "mod-replace", nil, func() error {
+		return c.gomod.Replace(path, newPath, version)
+	})
 }
@@ -1,6 +1,7 @@
 package golang
 
 import (
+	"context"
 	"fmt"
 	"path"
 
@@ -10,6 +11,7 @@ import (
 
 	"github.com/zoumo/make-rules/pkg/cli/common"
 	"github.com/zoumo/make-rules/pkg/golang"
+	"github.com/zoumo/make-rules/pkg/runner"
 )
 
 var _ cli.Command = &ModRequireCommand{}
@@ -17,12 +19,14 @@ var _ cli.ComplexOptions = &ModRequireCommand{}
 
 type ModRequireCommand struct {
 	*common.CommonOptions
+	goCmd runner.Executor
 	gomod *golang.GomodHelper
 }
 
 func NewModRequireCommand() *cobra.Command {
 	return cli.NewCobraCommand(&ModRequireCommand{
 		CommonOptions: common.NewCommonOptions(),
+		goCmd:         runner.NewRunner("go"),
 	})
 }
 
@@ -39,7 +43,7 @@ func (c *ModRequireCommand) Complete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	modfile := path.Join(c.Workspace, "go.mod")
-	c.gomod = golang.NewGomodHelper(modfile, c.Logger)
+	c.gomod = golang.NewGomodHelperWithExecutor(modfile, c.Logger, c.goCmd)
 	return nil
 }
 
@@ -53,5 +57,7 @@ func (c *ModRequireCommand) Run(cmd *cobra.Command, args []string) error {
 	}
 	path := args[0]
 	version := args[1]
-	return c.gomod.Require(path, version, false)
+	return c.RunWithHooks(context.Background(), "mod-require", nil, func() error {
+		return c.gomod.Require(path, version, false)
+	})
 }
@@ -1,6 +1,7 @@
 package golang
 
 import (
+	"context"
 	"path"
 
 	"github.com/spf13/cobra"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/zoumo/make-rules/pkg/cli/common"
 	"github.com/zoumo/make-rules/pkg/golang"
+	"github.com/zoumo/make-rules/pkg/runner"
 )
 
 var _ cli.Command = &ModTidyCommand{}
@@ -16,12 +18,14 @@ var _ cli.ComplexOptions = &ModTidyCommand{}
 
 type ModTidyCommand struct {
 	*common.CommonOptions
+	goCmd runner.Executor
 	gomod *golang.GomodHelper
 }
 
 func NewModTidyCommand() *cobra.Command {
 	return cli.NewCobraCommand(&ModTidyCommand{
 		CommonOptions: common.NewCommonOptions(),
+		goCmd:         runner.NewRunner("go"),
 	})
 }
 
@@ -38,7 +42,7 @@ func (c *ModTidyCommand) Complete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	modfile := path.Join(c.Workspace, "go.mod")
-	c.gomod = golang.NewGomodHelper(modfile, c.Logger)
+	c.gomod = golang.NewGomodHelperWithExecutor(modfile, c.Logger, c.goCmd)
 	return nil
 }
 
@@ -47,5 +51,5 @@ func (c *ModTidyCommand) Validate() error {
 }
 
 func (c *ModTidyCommand) Run(cmd *cobra.Command, args []string) error {
-	return c.gomod.PruneAndTidy()
+	return c.RunWithHooks(context.Background(), "mod-tidy", nil, c.gomod.PruneAndTidy)
 }
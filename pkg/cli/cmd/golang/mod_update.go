@@ -1,6 +1,7 @@
 package golang
 
 import (
+	"context"
 	"path"
 
 	"github.com/spf13/cobra"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/zoumo/make-rules/pkg/cli/common"
 	"github.com/zoumo/make-rules/pkg/golang"
+	"github.com/zoumo/make-rules/pkg/runner"
 )
 
 var _ cli.Command = &ModUpdateCommand{}
@@ -16,12 +18,14 @@ var _ cli.ComplexOptions = &ModUpdateCommand{}
 
 type ModUpdateCommand struct {
 	*common.CommonOptions
+	goCmd runner.Executor
 	gomod *golang.GomodHelper
 }
 
 func NewModUpdateCommand() *cobra.Command {
 	return cli.NewCobraCommand(&ModUpdateCommand{
 		CommonOptions: common.NewCommonOptions(),
+		goCmd:         runner.NewRunner("go"),
 	})
 }
 
@@ -38,7 +42,7 @@ func (c *ModUpdateCommand) Complete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	modfile := path.Join(c.Workspace, "go.mod")
-	c.gomod = golang.NewGomodHelper(modfile, c.Logger)
+	c.gomod = golang.NewGomodHelperWithExecutor(modfile, c.Logger, c.goCmd)
 	return nil
 }
 
@@ -47,6 +51,10 @@ func (c *ModUpdateCommand) Validate() error {
 }
 
 func (c *ModUpdateCommand) Run(cmd *cobra.Command, args []string) error {
+	return c.RunWithHooks(context.Background(), "mod-update", nil, c.run)
+}
+
+func (c *ModUpdateCommand) run() error {
 	// ensure requires
 	for _, r := range c.Config.Go.Mod.Require {
 		if err := c.gomod.Require(r.Path, r.Version, r.SkipDeps); err != nil {
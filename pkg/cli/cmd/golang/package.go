@@ -0,0 +1,370 @@
+package golang
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/zoumo/golib/cli"
+
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+const (
+	// ChecksumsFile is the combined checksums file of all archives
+	ChecksumsFile = "SHA256SUMS"
+)
+
+var (
+	_ cli.Command        = &GopackageCommand{}
+	_ cli.ComplexOptions = &GopackageCommand{}
+)
+
+// GopackageCommand packages binaries built by go build into one archive per
+// platform, and writes checksums of all archives
+type GopackageCommand struct {
+	*GobuildCommand
+
+	name *template.Template
+}
+
+func NewGoPackageCommand() *cobra.Command {
+	return cli.NewCobraCommand(&GopackageCommand{
+		GobuildCommand: newGobuildCommand(),
+	})
+}
+
+func (c *GopackageCommand) Name() string {
+	return "package"
+}
+
+func (c *GopackageCommand) BindFlags(fs *pflag.FlagSet) {
+	c.GobuildCommand.bindOutputFlags(fs)
+}
+
+func (c *GopackageCommand) Complete(cmd *cobra.Command, args []string) error {
+	if err := c.GobuildCommand.Complete(cmd, args); err != nil {
+		return err
+	}
+	name, err := template.New("archive").Option("missingkey=error").Parse(c.Config.Go.Build.Archives.Name)
+	if err != nil {
+		return fmt.Errorf("invalid archive name template %q: %w", c.Config.Go.Build.Archives.Name, err)
+	}
+	c.name = name
+	return nil
+}
+
+func (c *GopackageCommand) Validate() error {
+	if err := c.GobuildCommand.Validate(); err != nil {
+		return err
+	}
+	switch c.Config.Go.Build.Archives.Format {
+	case "", config.ArchiveTarGz, config.ArchiveZip:
+	default:
+		return fmt.Errorf("invalid archive format %q, it must be one of %s, %s", c.Config.Go.Build.Archives.Format, config.ArchiveTarGz, config.ArchiveZip)
+	}
+	// files with the same name overwrite each other in archive
+	for _, p := range c.platforms {
+		files, err := c.binaryFiles(p)
+		if err != nil {
+			return err
+		}
+		srcs := map[string]string{}
+		for _, f := range append(files, c.extraFiles()...) {
+			if src, ok := srcs[f.name]; ok {
+				return fmt.Errorf("%s and %s have the same name %s in archive of %s", src, f.src, f.name, p)
+			}
+			srcs[f.name] = f.src
+		}
+	}
+	return nil
+}
+
+// archiveFile is a file added into archive
+type archiveFile struct {
+	// src is the path of file on disk
+	src string
+	// name is the path of file in archive
+	name string
+	// target is the go build target of binary, it is empty for extra files
+	target string
+}
+
+func (c *GopackageCommand) Run(cmd *cobra.Command, args []string) error {
+	dir := c.Config.Go.Build.Archives.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(c.Workspace, dir)
+	}
+	env := map[string]string{
+		"MAKE_RULES_MODULE":       c.module,
+		"MAKE_RULES_VERSION":      c.versionInfo.GitVersion,
+		"MAKE_RULES_ARCHIVES_DIR": dir,
+	}
+	return c.RunWithHooks(context.Background(), "package", env, func() error {
+		return c.pack(dir)
+	})
+}
+
+// pack writes archives and checksums into dir
+func (c *GopackageCommand) pack(dir string) error {
+	if !runner.DryRun() {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	// use build date as modification time of files in archive, so archives
+	// of reproducible builds are reproducible too
+	modTime, err := time.Parse(time.RFC3339, c.versionInfo.BuildDate)
+	if err != nil {
+		modTime = time.Now()
+	}
+
+	// go build records the version of every binary in build cache
+	cache := loadBuildCache(path.Join(c.Workspace, "bin", BuildCacheFile))
+	checksums := []string{}
+	for _, p := range c.platforms {
+		files, err := c.binaryFiles(p)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if _, err := os.Stat(f.src); err != nil {
+				return fmt.Errorf("binary %s for %s is not found, run go build first: %w", f.src, p, err)
+			}
+			if err := c.checkBinary(cache, f, p); err != nil {
+				return err
+			}
+		}
+		if len(files) == 0 {
+			continue
+		}
+		files = append(files, c.extraFiles()...)
+
+		name, err := c.archiveName(p)
+		if err != nil {
+			return err
+		}
+		archive := filepath.Join(dir, name)
+		if runner.DryRun() {
+			c.Logger.Info("Dry run, archive is not written", "platform", p.String(), "archive", archive)
+			continue
+		}
+		c.Logger.Info("Go package", "platform", p.String(), "archive", archive)
+		if strings.HasSuffix(name, ".zip") {
+			err = writeZip(archive, files, modTime)
+		} else {
+			err = writeTarGz(archive, files, modTime)
+		}
+		if err != nil {
+			c.Logger.Error(err, "failed to create archive", "archive", archive)
+			return err
+		}
+
+		digest, err := fileSHA256(archive)
+		if err != nil {
+			return err
+		}
+		checksums = append(checksums, fmt.Sprintf("%s  %s\n", digest, name))
+	}
+
+	if runner.DryRun() {
+		return nil
+	}
+	sort.Strings(checksums)
+	sums := filepath.Join(dir, ChecksumsFile)
+	c.Logger.Info("Go package checksums", "file", sums)
+	return writeAtomic(sums, func(w io.Writer) error {
+		_, err := io.WriteString(w, strings.Join(checksums, ""))
+		return err
+	})
+}
+
+// binaryFiles returns binaries of all targets built for platform p
+func (c *GopackageCommand) binaryFiles(p platform) ([]archiveFile, error) {
+	files := []archiveFile{}
+	for _, target := range c.targets {
+		if !containsPlatform(c.targetPlatforms(target), p) {
+			continue
+		}
+		output, err := c.outputFile(p, target)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, archiveFile{src: output, name: filepath.Base(output), target: target})
+	}
+	return files, nil
+}
+
+// checkBinary returns an error if binary f for platform p is not built by go
+// build of the current version and profile, e.g. it is left by a build of
+// another version
+func (c *GopackageCommand) checkBinary(cache *buildCache, f archiveFile, p platform) error {
+	entry, ok := cache.Get(buildCacheKey(buildTask{target: f.target, platform: p}))
+	if !ok || entry.Output != f.src || entry.Version == nil {
+		return fmt.Errorf("binary %s for %s is not recorded by go build, run go build first", f.src, p)
+	}
+	built, want := entry.Version, c.versionInfo
+	if built.GitVersion != want.GitVersion || built.BuildProfile != want.BuildProfile {
+		return fmt.Errorf("binary %s for %s is built for version %s with profile %q, but packaging version %s with profile %q, run go build first",
+			f.src, p, built.GitVersion, built.BuildProfile, want.GitVersion, want.BuildProfile)
+	}
+	return nil
+}
+
+// extraFiles returns extra files bundled into every archive
+func (c *GopackageCommand) extraFiles() []archiveFile {
+	files := []archiveFile{}
+	for _, f := range c.Config.Go.Build.Archives.Files {
+		src := f
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(c.Workspace, src)
+		}
+		files = append(files, archiveFile{src: src, name: filepath.Base(f)})
+	}
+	return files
+}
+
+// archiveName renders archive name of platform with extension
+func (c *GopackageCommand) archiveName(p platform) (string, error) {
+	project := projectName(c.module)
+	buf := &bytes.Buffer{}
+	err := c.name.Execute(buf, map[string]string{
+		"Project": project,
+		"Version": c.versionInfo.GitVersion,
+		"OS":      p.GOOS,
+		"Arch":    p.GOARCH,
+		"Variant": p.Variant,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	format := c.Config.Go.Build.Archives.Format
+	if format == "" {
+		format = config.ArchiveTarGz
+		if p.GOOS == "windows" {
+			format = config.ArchiveZip
+		}
+	}
+	return buf.String() + "." + format, nil
+}
+
+func containsPlatform(platforms []platform, p platform) bool {
+	for _, pp := range platforms {
+		if pp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAtomic writes file by write into a temp file in the dir of file and
+// renames it to file on success, so a failed write leaves no truncated file
+func writeAtomic(file string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func writeTarGz(archive string, files []archiveFile, modTime time.Time) error {
+	return writeAtomic(archive, func(w io.Writer) error {
+		return writeTarGzTo(w, files, modTime)
+	})
+}
+
+func writeTarGzTo(w io.Writer, files []archiveFile, modTime time.Time) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, file := range files {
+		info, err := os.Stat(file.src)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:    file.name,
+			Mode:    int64(info.Mode().Perm()),
+			Size:    info.Size(),
+			ModTime: modTime,
+			Format:  tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if err := copyFile(tw, file.src); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func writeZip(archive string, files []archiveFile, modTime time.Time) error {
+	return writeAtomic(archive, func(w io.Writer) error {
+		return writeZipTo(w, files, modTime)
+	})
+}
+
+func writeZipTo(w io.Writer, files []archiveFile, modTime time.Time) error {
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		info, err := os.Stat(file.src)
+		if err != nil {
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = file.name
+		hdr.Method = zip.Deflate
+		hdr.Modified = modTime
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(fw, file.src); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func copyFile(w io.Writer, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,248 @@
+package golang
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/zoumo/make-rules/pkg/cli/common"
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/version"
+)
+
+func TestWriteArchives(t *testing.T) {
+	dir := t.TempDir()
+	files := []archiveFile{}
+	for name, data := range map[string]string{"foo": "binary", "LICENSE": "license"} {
+		src := filepath.Join(dir, "src", name)
+		if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(src, []byte(data), 0755); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, archiveFile{src: src, name: name})
+	}
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := map[string]string{}
+	for _, f := range files {
+		data, _ := os.ReadFile(f.src)
+		want[f.name] = string(data)
+	}
+
+	// tar.gz
+	archive := filepath.Join(dir, "a.tar.gz")
+	if err := writeTarGz(archive, files, modTime); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	got := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hdr.ModTime.Equal(modTime) {
+			t.Errorf("tar entry %s modtime = %v, want %v", hdr.Name, hdr.ModTime, modTime)
+		}
+		data, _ := io.ReadAll(tr)
+		got[hdr.Name] = string(data)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tar entries = %v, want %v", got, want)
+	}
+
+	// zip
+	archive = filepath.Join(dir, "a.zip")
+	if err := writeZip(archive, files, modTime); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	got = map[string]string{}
+	for _, zf := range zr.File {
+		if !zf.Modified.Equal(modTime) {
+			t.Errorf("zip entry %s modtime = %v, want %v", zf.Name, zf.Modified, modTime)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		got[zf.Name] = string(data)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("zip entries = %v, want %v", got, want)
+	}
+}
+
+func TestWriteArchives_Failed(t *testing.T) {
+	dir := t.TempDir()
+	files := []archiveFile{{src: filepath.Join(dir, "missing"), name: "foo"}}
+	for _, write := range []func(string, []archiveFile, time.Time) error{writeTarGz, writeZip} {
+		archive := filepath.Join(dir, "dist", "a")
+		if err := os.MkdirAll(filepath.Dir(archive), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := write(archive, files, time.Now()); err == nil {
+			t.Fatal("writing archive of missing file succeeded, want error")
+		}
+		// neither the archive nor the temp file is left
+		entries, err := os.ReadDir(filepath.Dir(archive))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("files are left after a failed write: %v", entries)
+		}
+	}
+}
+
+func TestGopackageCommand_ArchiveName(t *testing.T) {
+	tests := []struct {
+		format   string
+		platform platform
+		want     string
+	}{
+		{"", platform{GOOS: "linux", GOARCH: "amd64"}, "proj_v1.0.0_linux_amd64.tar.gz"},
+		{"", platform{GOOS: "linux", GOARCH: "arm", Variant: "v7"}, "proj_v1.0.0_linux_arm_v7.tar.gz"},
+		{"", platform{GOOS: "windows", GOARCH: "amd64"}, "proj_v1.0.0_windows_amd64.zip"},
+		{config.ArchiveZip, platform{GOOS: "linux", GOARCH: "amd64"}, "proj_v1.0.0_linux_amd64.zip"},
+		{config.ArchiveTarGz, platform{GOOS: "windows", GOARCH: "amd64"}, "proj_v1.0.0_windows_amd64.tar.gz"},
+	}
+	for _, tt := range tests {
+		cfg := config.New()
+		cfg.SetDefaults()
+		cfg.Go.Build.Archives.Format = tt.format
+		c := &GopackageCommand{
+			GobuildCommand: &GobuildCommand{
+				CommonOptions: &common.CommonOptions{Config: cfg},
+				module:        "example.com/proj/v2",
+				versionInfo:   version.Info{GitVersion: "v1.0.0"},
+			},
+			name: template.Must(template.New("archive").Parse(cfg.Go.Build.Archives.Name)),
+		}
+		got, err := c.archiveName(tt.platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("archiveName(%s) with format %q = %s, want %s", tt.platform, tt.format, got, tt.want)
+		}
+	}
+}
+
+// runTestPackage runs go package in workspace with fake go
+func runTestPackage(workspace string, cfg *config.Config, args ...string) error {
+	return execute(&GopackageCommand{GobuildCommand: newTestGobuild(workspace, withConfig(cfg))}, nil, args...)
+}
+
+func TestGopackageCommand_Checksums(t *testing.T) {
+	workspace := t.TempDir()
+	args := []string{"--platforms=linux/amd64,darwin/arm64", "--version=v1.0.0"}
+	if err := runTestBuild(workspace, args); err != nil {
+		t.Fatal(err)
+	}
+	if err := runTestPackage(workspace, config.New(), args...); err != nil {
+		t.Fatal(err)
+	}
+
+	dist := filepath.Join(workspace, config.DefaultArchivesDir)
+	lines := []string{}
+	for _, name := range []string{"proj_v1.0.0_darwin_arm64.tar.gz", "proj_v1.0.0_linux_amd64.tar.gz"} {
+		digest, err := fileSHA256(filepath.Join(dist, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s\n", digest, name))
+	}
+	// checksums are sorted lines
+	sort.Strings(lines)
+	want := strings.Join(lines, "")
+	got, err := os.ReadFile(filepath.Join(dist, ChecksumsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s:\n%s\nwant:\n%s", ChecksumsFile, got, want)
+	}
+}
+
+func TestGopackageCommand_BinaryVersion(t *testing.T) {
+	workspace := t.TempDir()
+	if err := runTestBuild(workspace, []string{"--platforms=linux/amd64", "--version=v1.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+	dist := filepath.Join(workspace, config.DefaultArchivesDir)
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		// binaries of the last build are of v1.0.0
+		{[]string{"--version=v1.1.0"}, "built for version v1.0.0"},
+		// binaries of the profile are not built
+		{[]string{"--version=v1.0.0", "--profile=release"}, "built for version v1.0.0 with profile \"\""},
+	} {
+		cfg := config.New()
+		cfg.Go.Build.Profiles = map[string]config.GoBuildProfile{"release": {}}
+		err := runTestPackage(workspace, cfg, append([]string{"--platforms=linux/amd64"}, tt.args...)...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Execute() with %v = %v, want error of %s", tt.args, err, tt.want)
+		}
+		if _, err := os.Stat(dist); !os.IsNotExist(err) {
+			entries, _ := os.ReadDir(dist)
+			if len(entries) != 0 {
+				t.Errorf("archives are written for binaries of another build: %v", entries)
+			}
+		}
+	}
+
+	// binaries not recorded by go build, e.g. built by hand
+	if err := os.Remove(filepath.Join(workspace, "bin", BuildCacheFile)); err != nil {
+		t.Fatal(err)
+	}
+	err := runTestPackage(workspace, config.New(), "--platforms=linux/amd64", "--version=v1.0.0")
+	if err == nil || !strings.Contains(err.Error(), "not recorded by go build") {
+		t.Errorf("Execute() without build cache = %v, want error of binaries not recorded", err)
+	}
+}
+
+func TestGopackageCommand_Validate_SameName(t *testing.T) {
+	for _, files := range [][]string{
+		{"docs/README.md", "README.md"},
+		{"hack/foo"},
+	} {
+		cfg := config.New()
+		cfg.Go.Build.Archives.Files = files
+		err := runTestPackage(t.TempDir(), cfg, "--platforms=linux/amd64")
+		if err == nil || !strings.Contains(err.Error(), "the same name") {
+			t.Errorf("Execute() with files %v = %v, want error of the same name", files, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package golang
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+// platform is a go build target platform. Variant is the optional sub
+// architecture of GOARCH, e.g. v7 of linux/arm/v7
+type platform struct {
+	GOOS    string
+	GOARCH  string
+	Variant string
+}
+
+func (p platform) String() string {
+	if p.Variant == "" {
+		return p.GOOS + "/" + p.GOARCH
+	}
+	return p.GOOS + "/" + p.GOARCH + "/" + p.Variant
+}
+
+// platformVariants are valid variants of GOARCH, and the go env keys they set
+var platformVariants = map[string]struct {
+	env    string
+	values []string
+}{
+	"arm":   {"GOARM", []string{"v5", "v6", "v7"}},
+	"amd64": {"GOAMD64", []string{"v1", "v2", "v3", "v4"}},
+	"386":   {"GO386", []string{"sse2", "softfloat"}},
+}
+
+// variantEnv returns go env of variant, e.g. GOARM=7 for linux/arm/v7
+func (p platform) variantEnv() []string {
+	if p.Variant == "" {
+		return nil
+	}
+	v := platformVariants[p.GOARCH]
+	value := p.Variant
+	if v.env == "GOARM" {
+		value = strings.TrimPrefix(value, "v")
+	}
+	return []string{v.env, value}
+}
+
+// hookSuffixes returns suffixes of per-platform hook files, e.g. linux,
+// linux_arm, linux_arm_v7
+func (p platform) hookSuffixes() []string {
+	suffixes := []string{p.GOOS, p.GOOS + "_" + p.GOARCH}
+	if p.Variant != "" {
+		suffixes = append(suffixes, p.GOOS+"_"+p.GOARCH+"_"+p.Variant)
+	}
+	return suffixes
+}
+
+// parsePlatform parses os/arch or os/arch/variant
+func parsePlatform(val string) (platform, error) {
+	ps := strings.Split(val, "/")
+	if len(ps) < 2 || len(ps) > 3 || ps[0] == "" || ps[1] == "" {
+		return platform{}, fmt.Errorf("invalid platform %q, it must be os/arch or os/arch/variant", val)
+	}
+	p := platform{GOOS: ps[0], GOARCH: ps[1]}
+	if len(ps) == 3 {
+		p.Variant = ps[2]
+		v, ok := platformVariants[p.GOARCH]
+		if !ok {
+			return platform{}, fmt.Errorf("invalid platform %q, arch %s has no variant", val, p.GOARCH)
+		}
+		if !contains(v.values, p.Variant) {
+			return platform{}, fmt.Errorf("invalid platform %q, variant of %s must be one of %s", val, p.GOARCH, strings.Join(v.values, ", "))
+		}
+	}
+	return p, nil
+}
+
+// readPlatforms parses platforms which are already checked by
+// expandPlatforms, invalid values are ignored
+func readPlatforms(vals []string) []platform {
+	platforms := []platform{}
+	for _, val := range vals {
+		if p, err := parsePlatform(val); err == nil {
+			platforms = append(platforms, p)
+		}
+	}
+	return platforms
+}
+
+// distPort is a platform supported by go, listed by go tool dist list
+type distPort struct {
+	GOOS         string
+	GOARCH       string
+	CgoSupported bool
+	FirstClass   bool
+}
+
+// loadDistPorts lists platforms supported by go
+func loadDistPorts(goCmd runner.Executor) ([]distPort, error) {
+	out, err := goCmd.ReadOnly().RunOutput("tool", "dist", "list", "-json")
+	if err != nil {
+		return nil, err
+	}
+	ports := []distPort{}
+	if err := json.Unmarshal(out, &ports); err != nil {
+		return nil, fmt.Errorf("failed to parse go tool dist list: %w", err)
+	}
+	return ports, nil
+}
+
+const (
+	// PlatformGroupAll are all platforms supported by go
+	PlatformGroupAll = "all"
+	// PlatformGroupFirstClass are first class ports of go
+	PlatformGroupFirstClass = "first-class"
+	// PlatformGroupCommon are common desktop and server platforms
+	PlatformGroupCommon = "common"
+	// PlatformGroupOSPrefix is the prefix of group of all platforms of an
+	// os, e.g. all-linux
+	PlatformGroupOSPrefix = "all-"
+)
+
+var commonPlatforms = []string{
+	"linux/amd64",
+	"linux/arm64",
+	"darwin/amd64",
+	"darwin/arm64",
+	"windows/amd64",
+}
+
+// expandPlatforms expands platform groups, and checks every platform is
+// supported by go according to ports. Duplicated platforms are removed.
+func expandPlatforms(vals []string, ports []distPort) ([]string, error) {
+	supported := map[string]bool{}
+	for _, p := range ports {
+		supported[p.GOOS+"/"+p.GOARCH] = true
+	}
+
+	result := []string{}
+	seen := map[string]bool{}
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	for _, val := range vals {
+		group, err := platformGroup(val, ports)
+		if err != nil {
+			return nil, err
+		}
+		if group != nil {
+			for _, p := range group {
+				add(p)
+			}
+			continue
+		}
+		p, err := parsePlatform(val)
+		if err != nil {
+			return nil, err
+		}
+		if !supported[p.GOOS+"/"+p.GOARCH] {
+			return nil, fmt.Errorf("unknown platform %q, run \"go tool dist list\" to see supported platforms", val)
+		}
+		add(p.String())
+	}
+	return result, nil
+}
+
+// platformGroup returns platforms of group name, nil is returned if name is
+// not a group
+func platformGroup(name string, ports []distPort) ([]string, error) {
+	group := []string{}
+	switch {
+	case name == PlatformGroupAll:
+		for _, p := range ports {
+			group = append(group, p.GOOS+"/"+p.GOARCH)
+		}
+	case name == PlatformGroupFirstClass:
+		for _, p := range ports {
+			if p.FirstClass {
+				group = append(group, p.GOOS+"/"+p.GOARCH)
+			}
+		}
+	case name == PlatformGroupCommon:
+		group = append(group, commonPlatforms...)
+	case strings.HasPrefix(name, PlatformGroupOSPrefix):
+		goos := strings.TrimPrefix(name, PlatformGroupOSPrefix)
+		for _, p := range ports {
+			if p.GOOS == goos {
+				group = append(group, p.GOOS+"/"+p.GOARCH)
+			}
+		}
+		if len(group) == 0 {
+			return nil, fmt.Errorf("unknown platform group %q, os %s is not supported by go", name, goos)
+		}
+	default:
+		return nil, nil
+	}
+	sort.Strings(group)
+	return group, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package golang
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		val     string
+		want    platform
+		wantEnv []string
+		wantErr bool
+	}{
+		{"linux/amd64", platform{GOOS: "linux", GOARCH: "amd64"}, nil, false},
+		{"linux/arm/v7", platform{GOOS: "linux", GOARCH: "arm", Variant: "v7"}, []string{"GOARM", "7"}, false},
+		{"linux/amd64/v3", platform{GOOS: "linux", GOARCH: "amd64", Variant: "v3"}, []string{"GOAMD64", "v3"}, false},
+		{"windows/386/softfloat", platform{GOOS: "windows", GOARCH: "386", Variant: "softfloat"}, []string{"GO386", "softfloat"}, false},
+		{"linux", platform{}, nil, true},
+		{"linux/", platform{}, nil, true},
+		{"linux/arm/v9", platform{}, nil, true},
+		{"linux/arm64/v8", platform{}, nil, true},
+		{"linux/arm/v7/x", platform{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			got, err := parsePlatform(tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parsePlatform() = %v, want %v", got, tt.want)
+			}
+			if err == nil && got.String() != tt.val {
+				t.Errorf("String() = %v, want %v", got.String(), tt.val)
+			}
+			if env := got.variantEnv(); !reflect.DeepEqual(env, tt.wantEnv) {
+				t.Errorf("variantEnv() = %v, want %v", env, tt.wantEnv)
+			}
+		})
+	}
+}
+
+func TestExpandPlatforms(t *testing.T) {
+	ports := []distPort{
+		{GOOS: "darwin", GOARCH: "amd64", FirstClass: true},
+		{GOOS: "darwin", GOARCH: "arm64", FirstClass: true},
+		{GOOS: "linux", GOARCH: "amd64", FirstClass: true},
+		{GOOS: "linux", GOARCH: "arm"},
+		{GOOS: "linux", GOARCH: "arm64", FirstClass: true},
+		{GOOS: "windows", GOARCH: "amd64", FirstClass: true},
+	}
+	tests := []struct {
+		name    string
+		vals    []string
+		want    []string
+		wantErr bool
+	}{
+		{"platforms", []string{"linux/arm/v7", "linux/amd64"}, []string{"linux/arm/v7", "linux/amd64"}, false},
+		{"os group", []string{"all-linux", "linux/amd64"}, []string{"linux/amd64", "linux/arm", "linux/arm64"}, false},
+		{"first class", []string{"first-class"}, []string{"darwin/amd64", "darwin/arm64", "linux/amd64", "linux/arm64", "windows/amd64"}, false},
+		{"common", []string{"common"}, []string{"darwin/amd64", "darwin/arm64", "linux/amd64", "linux/arm64", "windows/amd64"}, false},
+		{"unknown platform", []string{"linux/riscv64"}, nil, true},
+		{"unknown os group", []string{"all-plan9"}, nil, true},
+		{"malformed", []string{"linux"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandPlatforms(tt.vals, ports)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandPlatforms() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandPlatforms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package golang
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+// reproducibleEnvKeys are go env keys which change outputs of go build, they
+// are normalized in reproducible mode
+var reproducibleEnvKeys = []string{
+	"GOFLAGS",
+	"GOEXPERIMENT",
+	"GOAMD64",
+	"GOARM",
+	"GOARM64",
+	"GO386",
+	"GOMIPS",
+	"GOMIPS64",
+	"GOPPC64",
+	"GORISCV64",
+	"GOWASM",
+}
+
+// fileSHA256 returns the hex encoded sha256 digest of file
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeChecksum writes the sha256 digest of output to <output>.sha256 in the
+// format of sha256sum, and returns the digest
+func writeChecksum(output string) (string, error) {
+	digest, err := fileSHA256(output)
+	if err != nil {
+		return "", err
+	}
+	line := fmt.Sprintf("%s  %s\n", digest, path.Base(output))
+	if err := os.WriteFile(output+".sha256", []byte(line), 0644); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+// verify builds the task again into a temp dir with an empty GOCACHE, so
+// nothing is reused from the first build, and compares the digest of the
+// second binary with the first one.
+func (c *GobuildCommand) verify(ctx context.Context, logger log.Logger, task buildTask, cmd runner.Executor, target, digest string) error {
+	dir, err := os.MkdirTemp("", "make-rules-verify.*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	output, err := c.outputFile(task.platform, target)
+	if err != nil {
+		return err
+	}
+	output = path.Join(dir, path.Base(output))
+	args, err := c.gobuildArgs(task, output, target)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Go build verifying reproducibility", "module", target)
+	cmd = cmd.WithEnvs("GOCACHE", c.verifyCache)
+	env := buildEnv(cmd, task.config)
+	env["GOCACHE"] = c.verifyCache
+	if err := c.runGo(ctx, logger, cmd, env, output, args); err != nil {
+		logger.Error(err, "Go build failed", "module", target)
+		return err
+	}
+	second, err := fileSHA256(output)
+	if err != nil {
+		return err
+	}
+	if second != digest {
+		err := fmt.Errorf("build of %s for %s is not reproducible, digests %s and %s differ", task.target, task.platform, digest, second)
+		logger.Error(err, "Go build verification failed")
+		return err
+	}
+	logger.Info("Go build is reproducible", "module", target, "sha256", digest)
+	return nil
+}
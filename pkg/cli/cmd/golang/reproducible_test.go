@@ -0,0 +1,104 @@
+package golang
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+// lastBuild returns the last go build command run by goCmd
+func lastBuild(t *testing.T, goCmd *runner.Fake) runner.Call {
+	t.Helper()
+	calls := goCmd.Calls()
+	for i := len(calls) - 1; i >= 0; i-- {
+		if calls[i].Args[0] == "build" {
+			return calls[i]
+		}
+	}
+	t.Fatal("go build is not run")
+	return runner.Call{}
+}
+
+func TestGobuildCommand_Reproducible(t *testing.T) {
+	workspace := t.TempDir()
+	t.Setenv("GOAMD64", "v3")
+	t.Setenv("GOFLAGS", "-race")
+	t.Setenv("SOURCE_DATE_EPOCH", "1577836800")
+	goCmd := fakeGo()
+	if err := runTestBuild(workspace, []string{"--reproducible", "--platforms=linux/amd64", "cmd/foo"}, withGo(goCmd)); err != nil {
+		t.Fatal(err)
+	}
+
+	build := lastBuild(t, goCmd)
+	if cmdline := build.String(); !strings.Contains(cmdline, " -trimpath ") || !strings.Contains(cmdline, "buildDate=2020-01-01T00:00:00Z") {
+		t.Errorf("go build = %s, want -trimpath and build date from SOURCE_DATE_EPOCH", cmdline)
+	}
+	// host env changing outputs is normalized
+	for k, want := range map[string]string{"GOAMD64": "", "GOFLAGS": "", "CGO_ENABLED": "0"} {
+		if v, ok := build.Env[k]; !ok || v != want {
+			t.Errorf("go build env %s = %q, want %q", k, v, want)
+		}
+	}
+
+	output := filepath.Join(workspace, "bin/linux_amd64/foo")
+	digest, err := fileSHA256(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum, err := os.ReadFile(output + ".sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%s  foo\n", digest); string(checksum) != want {
+		t.Errorf("checksum = %q, want %q", checksum, want)
+	}
+
+	// the build date falls back to a fixed date out of git repo
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	if err := runTestBuild(workspace, []string{"--reproducible", "--force", "--platforms=linux/amd64", "cmd/foo"}, withGo(goCmd)); err != nil {
+		t.Fatal(err)
+	}
+	if cmdline := lastBuild(t, goCmd).String(); !strings.Contains(cmdline, "buildDate=1970-01-01T00:00:00Z") {
+		t.Errorf("go build = %s, want the fixed build date", cmdline)
+	}
+}
+
+func TestGobuildCommand_VerifyReproducible(t *testing.T) {
+	// fakeGoWriting returns fake go writing content of the call to the output
+	fakeGoWriting := func(content func(runner.Call) string) *runner.Fake {
+		goCmd := fakeGoWithoutBuild("cmd/foo", "cmd/bar")
+		goCmd.On("build", "...").Do(func(c runner.Call) error {
+			output := c.Args[len(c.Args)-2]
+			if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+				return err
+			}
+			return os.WriteFile(output, []byte(content(c)), 0755)
+		})
+		return goCmd
+	}
+
+	goCmd := fakeGoWriting(func(c runner.Call) string { return c.Args[len(c.Args)-1] })
+	if err := runTestBuild(t.TempDir(), []string{"--verify-reproducible", "--platforms=linux/amd64", "cmd/foo"}, withGo(goCmd)); err != nil {
+		t.Fatalf("verify reproducible build: %v", err)
+	}
+	builds := 0
+	for _, c := range goCmd.Calls() {
+		if c.Args[0] == "build" {
+			builds++
+		}
+	}
+	if builds != 2 {
+		t.Errorf("go build runs %d times, want 2", builds)
+	}
+
+	// the second build uses an empty GOCACHE
+	goCmd = fakeGoWriting(func(c runner.Call) string { return c.Env["GOCACHE"] })
+	err := runTestBuild(t.TempDir(), []string{"--verify-reproducible", "--platforms=linux/amd64", "cmd/foo"}, withGo(goCmd))
+	if err == nil || !strings.Contains(err.Error(), "is not reproducible") {
+		t.Errorf("verify unreproducible build = %v, want error", err)
+	}
+}
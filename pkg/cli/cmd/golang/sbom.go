@@ -0,0 +1,88 @@
+package golang
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/zoumo/golib/cli"
+
+	"github.com/zoumo/make-rules/pkg/runner"
+	"github.com/zoumo/make-rules/pkg/sbom"
+	"github.com/zoumo/make-rules/version"
+)
+
+var _ cli.Command = &GosbomCommand{}
+
+// GosbomCommand writes SBOMs of binaries built by go build
+type GosbomCommand struct {
+	*GobuildCommand
+}
+
+func NewGoSBOMCommand() *cobra.Command {
+	return cli.NewCobraCommand(&GosbomCommand{
+		GobuildCommand: newGobuildCommand(),
+	})
+}
+
+func (c *GosbomCommand) Name() string {
+	return "sbom"
+}
+
+func (c *GosbomCommand) BindFlags(fs *pflag.FlagSet) {
+	c.GobuildCommand.bindOutputFlags(fs)
+}
+
+func (c *GosbomCommand) Run(cmd *cobra.Command, args []string) error {
+	env := map[string]string{
+		"MAKE_RULES_MODULE":  c.module,
+		"MAKE_RULES_VERSION": c.versionInfo.GitVersion,
+	}
+	return c.RunWithHooks(context.Background(), "sbom", env, func() error {
+		for _, task := range c.tasks() {
+			output, err := c.outputFile(task.platform, path.Join(c.module, task.target))
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(output); err != nil {
+				return fmt.Errorf("binary of %s for %s is not found, run go build first: %w", task.target, task.platform, err)
+			}
+			info, err := inspectVersion(c.Logger, output, task.config)
+			if err != nil {
+				return err
+			}
+			info.Platform = task.platform.String()
+			if runner.DryRun() {
+				c.Logger.Info("Dry run, SBOM is not written", "target", task.target, "platform", task.platform.String())
+				continue
+			}
+			files, err := c.writeSBOM(output, info)
+			if err != nil {
+				c.Logger.Error(err, "failed to write SBOM", "output", output)
+				return err
+			}
+			c.Logger.Info("Go SBOM written", "target", task.target, "platform", task.platform.String(), "files", files)
+		}
+		return nil
+	})
+}
+
+// writeSBOM writes CycloneDX and SPDX SBOMs of binary output, go.sum of
+// workspace provides hashes of modules missing in the build info
+func (c *GobuildCommand) writeSBOM(output string, info version.Info) ([]string, error) {
+	sums, err := sbom.ReadGoSum(path.Join(c.Workspace, "go.sum"))
+	if err != nil {
+		return nil, err
+	}
+	a, err := sbom.NewArtifact(output, info, sums)
+	if err != nil {
+		return nil, err
+	}
+	if a.Version.GoVersion == "" {
+		a.Version.GoVersion = a.BuildInfo.GoVersion
+	}
+	return sbom.Write(a)
+}
@@ -1,6 +1,7 @@
 package golang
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -30,7 +31,7 @@ var _ cli.ComplexOptions = &GounittestCommand{}
 type GounittestCommand struct {
 	*common.CommonOptions
 
-	goCmd *runner.Runner
+	goCmd runner.Executor
 
 	allTests []string
 }
@@ -55,7 +56,7 @@ func (c *GounittestCommand) Complete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	out, err := c.goCmd.RunOutput("list", "-test", "./...")
+	out, err := c.goCmd.ReadOnly().RunOutput("list", "-test", "./...")
 	if err != nil {
 		c.Logger.Error(err, "failed to go list ./...", string(out))
 		return err
@@ -110,6 +111,15 @@ func (c *GounittestCommand) Validate() error {
 }
 
 func (c *GounittestCommand) Run(cmd *cobra.Command, args []string) error {
+	packages := []string{}
+	for _, test := range c.allTests {
+		packages = append(packages, strings.TrimSuffix(test, ".test"))
+	}
+	env := map[string]string{"MAKE_RULES_TEST_PACKAGES": strings.Join(packages, ",")}
+	return c.RunWithHooks(context.Background(), "unittest", env, c.run)
+}
+
+func (c *GounittestCommand) run() error {
 	for _, test := range c.allTests {
 		test = strings.TrimSuffix(test, ".test")
 		out, err := c.goCmd.RunCombinedOutput("test", test)
@@ -0,0 +1,195 @@
+package golang
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zoumo/golib/cli"
+
+	"github.com/zoumo/make-rules/pkg/cli/common"
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+const (
+	// InstallManifestFile is the file name of install manifest under <workspace>/bin/
+	InstallManifestFile = ".make-rules-install.json"
+)
+
+// InstallManifest records binaries installed by go install, so that go
+// uninstall removes exactly them
+type InstallManifest struct {
+	Files []InstalledFile `json:"files"`
+}
+
+// InstalledFile is a binary installed by go install
+type InstalledFile struct {
+	// Target is the target dir relative to workspace, e.g. cmd/foo
+	Target string `json:"target"`
+	// Path is the absolute path of installed file
+	Path string `json:"path"`
+	// Source is the binary built by go build
+	Source string `json:"source"`
+	// Symlink is true if Path is a symlink to Source
+	Symlink bool `json:"symlink"`
+	// SHA256 is the digest of copied file, it is empty for symlink
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// loadInstallManifest loads manifest from file, an empty manifest is
+// returned if file does not exist
+func loadInstallManifest(file string) (*InstallManifest, error) {
+	m := &InstallManifest{Files: []InstalledFile{}}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Add adds f into manifest, it replaces the file installed at the same path
+func (m *InstallManifest) Add(f InstalledFile) {
+	for i := range m.Files {
+		if m.Files[i].Path == f.Path {
+			m.Files[i] = f
+			return
+		}
+	}
+	m.Files = append(m.Files, f)
+}
+
+// Save writes manifest to file, file is removed if manifest is empty
+func (m *InstallManifest) Save(file string) error {
+	if len(m.Files) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Unchanged returns true if f is still the file installed by go install
+func (f InstalledFile) Unchanged() (bool, error) {
+	if f.Symlink {
+		link, err := os.Readlink(f.Path)
+		if err != nil {
+			return false, err
+		}
+		return link == f.Source, nil
+	}
+	digest, err := fileSHA256(f.Path)
+	if err != nil {
+		return false, err
+	}
+	return digest == f.SHA256, nil
+}
+
+var _ cli.Command = &GouninstallCommand{}
+
+// GouninstallCommand removes binaries recorded in install manifest
+type GouninstallCommand struct {
+	*common.CommonOptions
+}
+
+func NewGoUninstallCommand() *cobra.Command {
+	return cli.NewCobraCommand(&GouninstallCommand{
+		CommonOptions: common.NewCommonOptions(),
+	})
+}
+
+func (c *GouninstallCommand) Name() string {
+	return "uninstall"
+}
+
+func (c *GouninstallCommand) Run(cmd *cobra.Command, args []string) error {
+	return c.RunWithHooks(context.Background(), "uninstall", nil, func() error {
+		return c.uninstall(args)
+	})
+}
+
+// uninstall removes installed binaries of targets, all installed binaries
+// are removed if targets is empty. A binary changed since installed is not
+// removed, but it is dropped from manifest. If uninstall fails, manifest
+// keeps only the binaries which are not handled yet.
+func (c *GouninstallCommand) uninstall(targets []string) error {
+	manifestFile := path.Join(c.Workspace, "bin", InstallManifestFile)
+	manifest, err := loadInstallManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+	kept := []InstalledFile{}
+	for i, f := range manifest.Files {
+		fail := func(err error) error {
+			if runner.DryRun() {
+				return err
+			}
+			manifest.Files = append(kept, manifest.Files[i:]...)
+			if serr := manifest.Save(manifestFile); serr != nil {
+				c.Logger.Error(serr, "failed to save install manifest")
+			}
+			return err
+		}
+		if !matchInstalledTarget(f.Target, targets) {
+			kept = append(kept, f)
+			continue
+		}
+		logger := c.Logger.WithValues("target", f.Target, "path", f.Path)
+		unchanged, err := f.Unchanged()
+		switch {
+		case os.IsNotExist(err):
+			logger.Info("Go uninstall skipped, file does not exist")
+		case err != nil:
+			logger.Error(err, "failed to check installed file")
+			return fail(err)
+		case !unchanged:
+			logger.Info("Go uninstall skipped, file is changed since installed")
+		case runner.DryRun():
+			logger.Info("Dry run, file is not removed")
+		default:
+			logger.Info("Go uninstall")
+			if err := os.Remove(f.Path); err != nil {
+				logger.Error(err, "failed to remove installed file")
+				return fail(err)
+			}
+		}
+	}
+	if runner.DryRun() {
+		return nil
+	}
+	manifest.Files = kept
+	return manifest.Save(manifestFile)
+}
+
+// matchInstalledTarget returns true if target is one of inputs, inputs are
+// target dirs like cmd/foo or their base names. An empty inputs matches all.
+func matchInstalledTarget(target string, inputs []string) bool {
+	if len(inputs) == 0 {
+		return true
+	}
+	for _, input := range inputs {
+		input = strings.TrimSuffix(input, "/")
+		if input == target || input == path.Base(target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/config"
+)
+
+func TestInstalledFile_Unchanged(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, []byte("binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	copied := InstalledFile{Path: filepath.Join(dir, "copied"), Source: src}
+	var err error
+	if copied.SHA256, err = installFile(src, copied.Path); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(copied.Path); err != nil || info.Mode().Perm() != 0755 {
+		t.Fatalf("installed file = %v, %v", info, err)
+	}
+	linked := InstalledFile{Path: filepath.Join(dir, "linked"), Source: src, Symlink: true}
+	if err := installSymlink(src, linked.Path); err != nil {
+		t.Fatal(err)
+	}
+	// replace existing link
+	if err := installSymlink(src, linked.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []InstalledFile{copied, linked} {
+		if ok, err := f.Unchanged(); err != nil || !ok {
+			t.Errorf("Unchanged() of %s = %v, %v, want true", f.Path, ok, err)
+		}
+	}
+	if err := os.WriteFile(copied.Path, []byte("changed"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := copied.Unchanged(); ok {
+		t.Errorf("Unchanged() of changed file = true, want false")
+	}
+}
+
+func TestMatchInstalledTarget(t *testing.T) {
+	tests := []struct {
+		inputs []string
+		want   bool
+	}{
+		{nil, true},
+		{[]string{"cmd/foo"}, true},
+		{[]string{"foo/"}, true},
+		{[]string{"bar"}, false},
+	}
+	for _, tt := range tests {
+		if got := matchInstalledTarget("cmd/foo", tt.inputs); got != tt.want {
+			t.Errorf("matchInstalledTarget(%v) = %v, want %v", tt.inputs, got, tt.want)
+		}
+	}
+}
+
+func TestGouninstallCommand_PartialFailure(t *testing.T) {
+	workspace := t.TempDir()
+	bindir := filepath.Join(workspace, "gobin")
+	manifestFile := filepath.Join(workspace, "bin", InstallManifestFile)
+	if err := os.MkdirAll(bindir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := &InstallManifest{}
+	for _, name := range []string{"a", "b", "c"} {
+		src := filepath.Join(workspace, "bin", name)
+		if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(src, []byte(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		f := InstalledFile{Target: "cmd/" + name, Path: filepath.Join(bindir, name), Source: src}
+		var err error
+		if f.SHA256, err = installFile(src, f.Path); err != nil {
+			t.Fatal(err)
+		}
+		manifest.Add(f)
+	}
+	if err := manifest.Save(manifestFile); err != nil {
+		t.Fatal(err)
+	}
+	// b can not be checked, uninstall fails after a is removed
+	if err := os.Remove(filepath.Join(bindir, "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(bindir, "b", "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &GouninstallCommand{
+		CommonOptions: newTestCommonOptions(workspace, config.New()),
+	}
+	c.Logger = log.Log
+	if err := c.uninstall(nil); err == nil {
+		t.Fatal("uninstall() succeeded, want error of b")
+	}
+	if _, err := os.Stat(filepath.Join(bindir, "a")); !os.IsNotExist(err) {
+		t.Errorf("a is not removed: %v", err)
+	}
+	got, err := loadInstallManifest(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := []string{}
+	for _, f := range got.Files {
+		targets = append(targets, f.Target)
+	}
+	if want := []string{"cmd/b", "cmd/c"}; !reflect.DeepEqual(targets, want) {
+		t.Errorf("targets in manifest = %v, want %v", targets, want)
+	}
+}
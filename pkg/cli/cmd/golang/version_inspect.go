@@ -0,0 +1,145 @@
+package golang
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/zoumo/golib/cli"
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/cli/common"
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/git"
+	goutil "github.com/zoumo/make-rules/pkg/golang"
+	"github.com/zoumo/make-rules/version"
+)
+
+var _ cli.Command = &VersionInspectCommand{}
+
+// VersionInspectCommand prints version info embedded in go binaries without
+// running them
+type VersionInspectCommand struct {
+	*common.CommonOptions
+
+	// versionPackage is set by flag, it takes precedence over the config
+	// file if it is set in command line
+	versionPackage string
+}
+
+func NewVersionInspectCommand() *cobra.Command {
+	cmd := cli.NewCobraCommand(&VersionInspectCommand{
+		CommonOptions:  common.NewCommonOptions(),
+		versionPackage: config.DefaultVersionPackage,
+	})
+	cmd.Use = "inspect <binary...>"
+	cmd.Args = cobra.MinimumNArgs(1)
+	return cmd
+}
+
+func (c *VersionInspectCommand) Name() string {
+	return "inspect"
+}
+
+func (c *VersionInspectCommand) BindFlags(fs *pflag.FlagSet) {
+	c.CommonOptions.BindFlags(fs)
+	fs.StringVar(&c.versionPackage, "version-package", c.versionPackage, "package receiving version variables by -X")
+}
+
+func (c *VersionInspectCommand) Complete(cmd *cobra.Command, args []string) error {
+	if err := c.CommonOptions.Complete(cmd, args); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("version-package") {
+		c.Config.Go.Build.VersionPackage = c.versionPackage
+	}
+	return nil
+}
+
+func (c *VersionInspectCommand) Run(cmd *cobra.Command, args []string) error {
+	infos := map[string]version.Info{}
+	for _, file := range args {
+		info, err := inspectVersion(c.Logger, file, c.Config.Go.Build)
+		if err != nil {
+			return err
+		}
+		infos[file] = info
+	}
+	if len(args) == 1 {
+		fmt.Fprintln(cmd.OutOrStdout(), infos[args[0]].PrettyJSON())
+		return nil
+	}
+	out, err := json.MarshalIndent(infos, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(out))
+	return nil
+}
+
+// inspectVersion reads version info of go binary file. Version variables are
+// read from the data section by symbols of cfg, or from -X of -ldflags in
+// build info if the binary is stripped. Git commit and tree state fall back
+// to the vcs stamp of go.
+func inspectVersion(logger log.Logger, file string, cfg config.GoBuild) (version.Info, error) {
+	bi, err := buildinfo.ReadFile(file)
+	if err != nil {
+		return version.Info{}, fmt.Errorf("failed to read build info of %s: %w", file, err)
+	}
+	settings := map[string]string{}
+	for _, s := range bi.Settings {
+		settings[s.Key] = s.Value
+	}
+
+	symbols := []string{}
+	for _, name := range VersionVariables {
+		if symbol := versionSymbol(cfg.VersionPackage, cfg.VersionVariables, name); symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+	vars, err := goutil.ReadStringVars(file, symbols)
+	if err != nil {
+		logger.V(1).Info("failed to read version variables from data section, fall back to ldflags", "binary", file, "reason", err.Error())
+		vars = map[string]string{}
+	}
+	for symbol, value := range goutil.ParseLDFlagsX(settings["-ldflags"]) {
+		if _, ok := vars[symbol]; !ok {
+			vars[symbol] = value
+		}
+	}
+	value := func(name string) string {
+		return vars[versionSymbol(cfg.VersionPackage, cfg.VersionVariables, name)]
+	}
+
+	info := version.Info{
+		GitVersion:   value("gitVersion"),
+		GitCommit:    value("gitCommit"),
+		GitRemote:    value("gitRemote"),
+		GitTreeState: value("gitTreeState"),
+		BuildDate:    value("buildDate"),
+		BuildProfile: value("buildProfile"),
+		GoVersion:    bi.GoVersion,
+		Compiler:     settings["-compiler"],
+		Platform:     settings["GOOS"] + "/" + settings["GOARCH"],
+	}
+	if info.GitVersion == "" || info.GitVersion == "unknown" {
+		info.GitVersion = bi.Main.Version
+	}
+	if info.GitCommit == "" {
+		info.GitCommit = settings["vcs.revision"]
+	}
+	if info.GitTreeState == "" {
+		switch settings["vcs.modified"] {
+		case "true":
+			info.GitTreeState = string(git.GitTreeDirty)
+		case "false":
+			info.GitTreeState = string(git.GitTreeClean)
+		}
+	}
+	if info.Compiler == "" {
+		info.Compiler = "gc"
+	}
+	return info, nil
+}
@@ -5,6 +5,11 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/git"
+	"github.com/zoumo/make-rules/pkg/runner"
 )
 
 // FindTargetsFrom find all dir under {workdir}/{subdir}/ with 1 depth as target
@@ -52,6 +57,79 @@ func FindTargetsFrom(workdir, subdir string, mustContainFile string) (targets []
 	return filtered, nil
 }
 
+// FindMainPackages finds all "package main" in module by go list, and
+// returns their dirs relative to module root, e.g. ["cmd/a", "tools/b"].
+// The main package in module root is "."
+func FindMainPackages(goCmd runner.Executor, module string) ([]string, error) {
+	out, err := goCmd.ReadOnly().RunOutput("list", "-e", "-f", "{{.Name}} {{.ImportPath}}", "./...")
+	if err != nil {
+		return nil, err
+	}
+	targets := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "main" {
+			continue
+		}
+		if fields[1] == module {
+			targets = append(targets, ".")
+			continue
+		}
+		rel := strings.TrimPrefix(fields[1], module+"/")
+		if rel == fields[1] {
+			// main package outside module
+			continue
+		}
+		targets = append(targets, rel)
+	}
+	return targets, nil
+}
+
+// MatchTargets returns targets which match any include glob and do not match
+// any exclude glob. A glob ending with "/..." matches the dir and all its
+// sub dirs, otherwise it is matched by path.Match
+func MatchTargets(targets, include, exclude []string) []string {
+	matched := []string{}
+	for _, t := range targets {
+		if matchAny(t, include) && !matchAny(t, exclude) {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
+
+func matchAny(target string, globs []string) bool {
+	for _, g := range globs {
+		if MatchGlob(g, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob reports whether target matches glob
+func MatchGlob(glob, target string) bool {
+	if prefix, ok := strings.CutSuffix(glob, "/..."); ok {
+		if target == prefix || strings.HasPrefix(target, prefix+"/") {
+			return true
+		}
+		// prefix may be a glob too
+		dir := target
+		for dir != "." && dir != "/" {
+			if ok, _ := path.Match(prefix, dir); ok {
+				return true
+			}
+			dir = path.Dir(dir)
+		}
+		return false
+	}
+	ok, _ := path.Match(glob, target)
+	return ok
+}
+
+// FilterTargets returns targets specified by inputs, an input matches a
+// target if it equals to the target or {prefix}/{input}. All targets are
+// returned if inputs is empty
 func FilterTargets(inputs []string, allTargets []string, prefix string) []string {
 	// find build target
 	if len(inputs) == 0 {
@@ -59,15 +137,45 @@ func FilterTargets(inputs []string, allTargets []string, prefix string) []string
 	}
 	filtered := []string{}
 	for _, input := range inputs {
-		if !strings.HasPrefix(input, prefix+"/") {
-			input = prefix + "/" + input
-		}
+		input = strings.TrimSuffix(input, "/")
 		for _, t := range allTargets {
 			// check if target is valid
-			if t == input {
-				filtered = append(filtered, input)
+			if t == input || t == prefix+"/"+input {
+				filtered = append(filtered, t)
+				break
 			}
 		}
 	}
 	return filtered
 }
+
+// GitVersion returns the version of go build, it is override if set,
+// otherwise the semantic version described by git. The version has suffix
+// -dirty if the git tree is dirty. It is v0.0.0 if repo is nil, e.g. the
+// workspace is not a git repo.
+func GitVersion(logger log.Logger, repo *git.Repository, override string) string {
+	version := "v0.0.0"
+	if override != "" {
+		version = override
+	}
+	if repo == nil {
+		return version
+	}
+	state, err := repo.TreeState()
+	if err != nil {
+		logger.Error(err, "failed to detect git tree state")
+		return version
+	}
+	if override == "" {
+		desc, err := repo.Describe(nil)
+		if err != nil {
+			logger.Error(err, "failed to describe git for HEAD")
+			return version
+		}
+		version = desc.SemanticVersion()
+	}
+	if state == git.GitTreeDirty {
+		version += "-dirty"
+	}
+	return version
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+func TestMatchTargets(t *testing.T) {
+	targets := []string{"cmd/a", "cmd/internal-b", "cmd/tools/c", "tools/d", "tools/e/f", "examples/g"}
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{"default", []string{"cmd/*"}, nil, []string{"cmd/a", "cmd/internal-b"}},
+		{"exclude", []string{"cmd/*"}, []string{"cmd/internal-*"}, []string{"cmd/a"}},
+		{"recursive", []string{"cmd/...", "tools/..."}, nil, []string{"cmd/a", "cmd/internal-b", "cmd/tools/c", "tools/d", "tools/e/f"}},
+		{"recursive glob", []string{"*/..."}, []string{"examples/..."}, []string{"cmd/a", "cmd/internal-b", "cmd/tools/c", "tools/d", "tools/e/f"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchTargets(targets, tt.include, tt.exclude); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindMainPackages(t *testing.T) {
+	goCmd := runner.NewFake("go")
+	goCmd.On("list", "...").Return(`main example.com/proj
+main example.com/proj/cmd/a
+util example.com/proj/pkg/util
+main example.com/other/cmd/b
+`, "", 0)
+	got, err := FindMainPackages(goCmd, "example.com/proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{".", "cmd/a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindMainPackages() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/zoumo/make-rules/pkg/git"
+	"github.com/zoumo/make-rules/pkg/hook"
+)
+
+// HookEnv returns env passed to hooks of all commands
+func (o *CommonOptions) HookEnv(command string) map[string]string {
+	return map[string]string{
+		"MAKE_RULES_COMMAND":   command,
+		"MAKE_RULES_WORKSPACE": o.Workspace,
+	}
+}
+
+// HookCondition returns the condition of hook steps running on local
+// platform
+func (o *CommonOptions) HookCondition() hook.Condition {
+	cond := hook.Condition{
+		Platforms: []string{runtime.GOOS + "/" + runtime.GOARCH},
+	}
+	repo, err := git.Open(o.Workspace)
+	if err != nil {
+		return cond
+	}
+	state, err := repo.TreeState()
+	cond.CleanTree = err == nil && state == git.GitTreeClean
+	return cond
+}
+
+// RunWithHooks runs pre-<command> hooks, run and post-<command> hooks in
+// order. Hooks are files in Config.Hooks.Dir and steps in Config.Hooks.Steps,
+// env are extra env passed to hooks besides HookEnv.
+func (o *CommonOptions) RunWithHooks(ctx context.Context, command string, env map[string]string, run func() error) error {
+	dir := o.Config.Hooks.Dir
+	if dir != "" && !filepath.IsAbs(dir) {
+		dir = filepath.Join(o.Workspace, dir)
+	}
+	timeout, _ := time.ParseDuration(o.Config.Hooks.Timeout)
+	hooks := (&hook.Runner{
+		Timeout:     timeout,
+		Env:         o.HookEnv(command),
+		Dir:         o.Workspace,
+		NewExecutor: o.HookExecutor,
+	}).WithEnv(env)
+	cond := o.HookCondition()
+
+	pre := hook.Pre(command)
+	if err := hooks.Run(ctx, o.Logger, dir, pre); err != nil {
+		return err
+	}
+	if err := hooks.RunSteps(ctx, o.Logger, pre, o.Config.Hooks.Steps[string(pre)], cond); err != nil {
+		return err
+	}
+	if err := run(); err != nil {
+		return err
+	}
+	post := hook.Post(command)
+	if err := hooks.Run(ctx, o.Logger, dir, post); err != nil {
+		return err
+	}
+	return hooks.RunSteps(ctx, o.Logger, post, o.Config.Hooks.Steps[string(post)], cond)
+}
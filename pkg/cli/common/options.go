@@ -1,11 +1,19 @@
 package common
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/zoumo/golib/cli"
 
 	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/environ"
+	"github.com/zoumo/make-rules/pkg/runner"
 )
 
 // CommonOptions provides common options for all commands.
@@ -14,6 +22,12 @@ import (
 type CommonOptions struct {
 	*cli.CommonOptions // Provides Logger and Workspace fields
 	Config             *config.Config
+	// Env is the env of external commands run by this command, it is
+	// resolved in Complete
+	Env *environ.Env
+	// HookExecutor returns the executor of commands run by hooks, nil means
+	// runner.NewRunner. Tests set it to script hooks by runner.Fake.
+	HookExecutor func(name string) runner.Executor
 }
 
 // BindFlags implements cli.Options interface.
@@ -29,27 +43,68 @@ func (o *CommonOptions) Complete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Load config if not set
 	if o.Config == nil {
-		cfg, err := config.Load()
-		if err != nil {
-			o.Config = config.New()
-		} else {
-			o.Config = cfg
-		}
+		o.Config = config.New()
+	}
+	if err := o.loadConfig(); err != nil {
+		return err
 	}
 	o.Config.SetDefaults()
 
+	// resolve env of external commands, runner applies it to all commands
+	env, err := environ.Resolve(o.Config.Env, o.Workspace, CommandName(cmd), os.Environ(), environ.FlagEnv())
+	if err != nil {
+		return err
+	}
+	o.Env = env
+	runner.SetEnv(env.Map())
+	return nil
+}
+
+// loadConfig loads make-rules.yaml in workspace into Config in place, Config
+// is kept as is if the file does not exist. Flags must not be bound to fields
+// of Config, loading overwrites them. Commands keep flag values in their own
+// fields and merge the ones set in command line into Config after Complete,
+// so that they take precedence over the config file.
+func (o *CommonOptions) loadConfig() error {
+	file := filepath.Join(o.Workspace, config.ConfigPath)
+	cfg, err := config.LoadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load config %s: %w", file, err)
+	}
+	*o.Config = *cfg
 	return nil
 }
 
+// CommandName returns the name of cmd used by hooks and env config, it is
+// the command path without root and go command joined by "-", e.g.
+// mod-update of "make-rules go mod update", container-build of
+// "make-rules container build"
+func CommandName(cmd *cobra.Command) string {
+	names := []string{}
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		names = append([]string{c.Name()}, names...)
+	}
+	if len(names) > 1 && names[0] == "go" {
+		names = names[1:]
+	}
+	return strings.Join(names, "-")
+}
+
 // Validate implements cli.ComplexOptions interface.
 // MUST call embedded CommonOptions.Validate first.
 func (o *CommonOptions) Validate() error {
-	return o.CommonOptions.Validate()
+	if err := o.CommonOptions.Validate(); err != nil {
+		return err
+	}
+	return o.Config.Hooks.Validate()
 }
 
-// NewCommonOptions creates a new CommonOptions with initialized config.
+// NewCommonOptions creates a new CommonOptions with default config. The
+// config file in workspace is loaded in Complete.
 func NewCommonOptions() *CommonOptions {
 	return &CommonOptions{
 		CommonOptions: &cli.CommonOptions{},
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zoumo/make-rules/pkg/config"
+)
+
+func TestCommonOptions_Complete(t *testing.T) {
+	workspace := t.TempDir()
+	file := filepath.Join(workspace, config.ConfigPath)
+
+	// default config is kept if there is no config file
+	o := NewCommonOptions()
+	o.Workspace = workspace
+	if err := o.Complete(&cobra.Command{Use: "build"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := o.Config.Go.Build.Platforms; !reflect.DeepEqual(got, config.DefaultPlatforms) {
+		t.Errorf("platforms = %v, want default %v", got, config.DefaultPlatforms)
+	}
+
+	// config file is loaded in place with defaults
+	data := "go:\n  build:\n    platforms: [linux/amd64]\n    tags: [netgo]\n"
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o = NewCommonOptions()
+	o.Workspace = workspace
+	cfg := o.Config
+	if err := o.Complete(&cobra.Command{Use: "build"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if o.Config != cfg {
+		t.Error("config is replaced, want it loaded in place")
+	}
+	if got := o.Config.Go.Build.Platforms; !reflect.DeepEqual(got, []string{"linux/amd64"}) {
+		t.Errorf("platforms = %v, want config value", got)
+	}
+	if got := o.Config.Go.Build.Tags; !reflect.DeepEqual(got, []string{"netgo"}) {
+		t.Errorf("tags = %v, want config value", got)
+	}
+	if o.Config.Go.Build.Parallelism != 1 {
+		t.Errorf("parallelism = %d, want default 1", o.Config.Go.Build.Parallelism)
+	}
+
+	// malformed config file is an error
+	if err := os.WriteFile(file, []byte("go: [broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o = NewCommonOptions()
+	o.Workspace = workspace
+	if err := o.Complete(&cobra.Command{Use: "build"}, nil); err == nil {
+		t.Error("Complete() with malformed config succeeded, want error")
+	}
+}
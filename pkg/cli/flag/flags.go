@@ -5,6 +5,9 @@ import (
 
 	"github.com/spf13/pflag"
 	"github.com/zoumo/golib/log/consolog"
+
+	"github.com/zoumo/make-rules/pkg/environ"
+	"github.com/zoumo/make-rules/pkg/runner"
 )
 
 // WordSepNormalizeFunc changes all flags that contain "_" separators
@@ -18,6 +21,8 @@ func WordSepNormalizeFunc(f *pflag.FlagSet, name string) pflag.NormalizedName {
 // AddGlobalFlags registers global flags
 func AddGlobalFlags(fs *pflag.FlagSet) {
 	consolog.InitFlags(fs)
+	runner.InitFlags(fs)
+	environ.InitFlags(fs)
 }
 
 // normalize replaces underscores with hyphens
@@ -3,7 +3,11 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"path"
 	"runtime"
+	"sort"
+	"strings"
+	"time"
 
 	"sigs.k8s.io/yaml"
 )
@@ -13,7 +17,15 @@ const (
 )
 
 var (
-	DefaultPlatforms = []string{fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)}
+	DefaultPlatforms     = []string{fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)}
+	DefaultGoBuildOutput = "{{.Workspace}}/bin/{{.OS}}_{{.Arch}}{{with .Variant}}_{{.}}{{end}}/{{.Name}}{{.Ext}}"
+	// DefaultVersionPackage is the package receiving version variables by default
+	DefaultVersionPackage = "github.com/zoumo/make-rules/version"
+
+	DefaultTargetsInclude = []string{"cmd/*"}
+
+	DefaultArchivesDir  = "dist"
+	DefaultArchivesName = "{{.Project}}_{{.Version}}_{{.OS}}_{{.Arch}}{{with .Variant}}_{{.}}{{end}}"
 )
 
 func New() *Config {
@@ -26,10 +38,36 @@ func (c *Config) SetDefaults() {
 	if len(c.Go.Build.Platforms) == 0 {
 		c.Go.Build.Platforms = DefaultPlatforms
 	}
+	if c.Go.Build.Parallelism == 0 {
+		c.Go.Build.Parallelism = 1
+	}
+	if c.Go.Build.BuildDate == "" {
+		c.Go.Build.BuildDate = BuildDateNow
+	}
+	if c.Go.Build.Output == "" {
+		c.Go.Build.Output = DefaultGoBuildOutput
+	}
+	if c.Go.Build.VersionPackage == "" {
+		c.Go.Build.VersionPackage = DefaultVersionPackage
+	}
+	if len(c.Go.Build.Targets.Include) == 0 {
+		c.Go.Build.Targets.Include = DefaultTargetsInclude
+	}
+	if c.Go.Build.Archives.Dir == "" {
+		c.Go.Build.Archives.Dir = DefaultArchivesDir
+	}
+	if c.Go.Build.Archives.Name == "" {
+		c.Go.Build.Archives.Name = DefaultArchivesName
+	}
 }
 
 func Load() (*Config, error) {
-	data, err := ioutil.ReadFile(ConfigPath)
+	return LoadFile(ConfigPath)
+}
+
+// LoadFile loads config from file
+func LoadFile(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
@@ -49,3 +87,211 @@ func LoadOrDie() *Config {
 	}
 	return cfg
 }
+
+// ResolveOverrides re-keys Overrides by the full path of targets, e.g.
+// cmd/server. A key is either the full path or the base name of a target,
+// e.g. server, it is an error if the key matches no target or its base name
+// matches more than one target.
+func (t *GoBuildTargets) ResolveOverrides(targets []string) error {
+	if len(t.Overrides) == 0 {
+		return nil
+	}
+	resolved := map[string]GoBuildTarget{}
+	for key, o := range t.Overrides {
+		matched := []string{}
+		for _, target := range targets {
+			if target == key {
+				matched = []string{target}
+				break
+			}
+			if path.Base(target) == key {
+				matched = append(matched, target)
+			}
+		}
+		switch len(matched) {
+		case 0:
+			return fmt.Errorf("targets.%s matches no target, targets are %s", key, strings.Join(targets, ", "))
+		case 1:
+		default:
+			return fmt.Errorf("targets.%s is ambiguous, it matches %s, use the full path instead", key, strings.Join(matched, ", "))
+		}
+		if _, ok := resolved[matched[0]]; ok {
+			return fmt.Errorf("target %s is overridden more than once", matched[0])
+		}
+		resolved[matched[0]] = o
+	}
+	t.Overrides = resolved
+	return nil
+}
+
+// ForTarget returns the go build settings of target merged with its
+// overrides in Targets, target is the path resolved by ResolveOverrides. The returned value has no Targets.Overrides, and
+// its Hooks are the hooks of target.
+func (b GoBuild) ForTarget(name string) GoBuild {
+	merged := b
+	merged.Targets.Overrides = nil
+	// global hooks run once for all targets
+	merged.Hooks = GoBuildHooks{}
+	merged.Flags = append([]string{}, b.Flags...)
+	merged.LDFlags = append([]string{}, b.LDFlags...)
+	merged.GCFlags = append([]string{}, b.GCFlags...)
+	merged.Tags = append([]string{}, b.Tags...)
+	merged.Env = map[string]string{}
+	for k, v := range b.Env {
+		merged.Env[k] = v
+	}
+
+	t, ok := b.Targets.Overrides[name]
+	if !ok {
+		return merged
+	}
+	if len(t.Platforms) > 0 {
+		merged.Platforms = t.Platforms
+	}
+	merged.Flags = append(merged.Flags, t.Flags...)
+	merged.LDFlags = append(merged.LDFlags, t.LDFlags...)
+	merged.GCFlags = append(merged.GCFlags, t.GCFlags...)
+	merged.Tags = append(merged.Tags, t.Tags...)
+	for k, v := range t.Env {
+		merged.Env[k] = v
+	}
+	merged.Hooks = t.Hooks
+	return merged
+}
+
+// WithProfile returns the go build settings merged with profile name, they
+// are returned unchanged if name is empty.
+func (b GoBuild) WithProfile(name string) (GoBuild, error) {
+	if name == "" {
+		return b, nil
+	}
+	p, ok := b.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(b.Profiles))
+		for n := range b.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return b, fmt.Errorf("unknown go build profile %q, defined profiles are [%s]", name, strings.Join(names, ", "))
+	}
+	merged := b
+	merged.Profile = name
+	merged.Flags = append(append([]string{}, b.Flags...), p.Flags...)
+	merged.LDFlags = append(append([]string{}, b.LDFlags...), p.LDFlags...)
+	merged.GCFlags = append(append([]string{}, b.GCFlags...), p.GCFlags...)
+	merged.Tags = append(append([]string{}, b.Tags...), p.Tags...)
+	merged.Env = map[string]string{}
+	for k, v := range b.Env {
+		merged.Env[k] = v
+	}
+	for k, v := range p.Env {
+		merged.Env[k] = v
+	}
+	if p.Output != "" {
+		merged.Output = p.Output
+	}
+	return merged, nil
+}
+
+// CGOEnabled returns the value of CGO_ENABLED decided by CGO, Env and Static
+// in order, an empty string means it is not set.
+func (b GoBuild) CGOEnabled() string {
+	if b.CGO != nil {
+		if *b.CGO {
+			return "1"
+		}
+		return "0"
+	}
+	if v, ok := b.Env["CGO_ENABLED"]; ok {
+		return v
+	}
+	if b.Static {
+		return "0"
+	}
+	return ""
+}
+
+// Validate checks conflicts between go build settings
+func (b GoBuild) Validate() error {
+	for _, f := range b.Flags {
+		name := strings.SplitN(strings.TrimLeft(f, "-"), "=", 2)[0]
+		switch {
+		case name == "tags" && len(b.Tags) > 0:
+			return fmt.Errorf("flag %q conflicts with tags, use tags only", f)
+		case name == "trimpath" && b.Trimpath:
+			return fmt.Errorf("flag %q conflicts with trimpath, use trimpath only", f)
+		case name == "buildmode" && b.BuildMode != "":
+			return fmt.Errorf("flag %q conflicts with buildmode, use buildmode only", f)
+		}
+	}
+
+	if v, ok := b.Env["CGO_ENABLED"]; ok && b.CGO != nil && v != b.CGOEnabled() {
+		return fmt.Errorf("env CGO_ENABLED=%s conflicts with cgo=%v", v, *b.CGO)
+	}
+
+	switch b.BuildMode {
+	case "c-archive", "c-shared":
+		if b.CGOEnabled() == "0" {
+			return fmt.Errorf("buildmode %s requires cgo, but cgo is disabled", b.BuildMode)
+		}
+	}
+	if b.Static {
+		switch b.BuildMode {
+		case "c-shared", "shared", "plugin":
+			return fmt.Errorf("static conflicts with buildmode %s", b.BuildMode)
+		}
+	}
+	return b.Hooks.Validate()
+}
+
+// Validate checks hook names, timeout and steps
+func (h Hooks) Validate() error {
+	if h.Timeout != "" {
+		if _, err := time.ParseDuration(h.Timeout); err != nil {
+			return fmt.Errorf("invalid hooks.timeout %q: %w", h.Timeout, err)
+		}
+	}
+	names := make([]string, 0, len(h.Steps))
+	for name := range h.Steps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !strings.HasPrefix(name, "pre-") && !strings.HasPrefix(name, "post-") {
+			return fmt.Errorf("invalid hooks.steps.%s, hook name must start with pre- or post-", name)
+		}
+		// go build runs its own hooks
+		if name == "pre-build" || name == "post-build" {
+			return fmt.Errorf("invalid hooks.steps.%s, go build hooks are declared in go.build.hooks", name)
+		}
+		if err := validateHookSteps("hooks.steps."+name, h.Steps[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks every hook step has a command and valid platform globs
+func (h GoBuildHooks) Validate() error {
+	if err := validateHookSteps("hooks.pre", h.Pre); err != nil {
+		return err
+	}
+	return validateHookSteps("hooks.post", h.Post)
+}
+
+func validateHookSteps(field string, steps []HookStep) error {
+	for i, step := range steps {
+		if step.Command == "" {
+			return fmt.Errorf("%s[%d]: command is required", field, i)
+		}
+		if step.When == nil {
+			continue
+		}
+		for _, p := range step.When.Platforms {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("%s[%d]: invalid platform glob %q: %w", field, i, p, err)
+			}
+		}
+	}
+	return nil
+}
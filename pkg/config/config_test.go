@@ -0,0 +1,204 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestGoBuild_ForTarget(t *testing.T) {
+	b := GoBuild{
+		Platforms: []string{"linux/amd64", "darwin/arm64"},
+		Flags:     []string{"-v"},
+		LDFlags:   []string{"-s"},
+		Tags:      []string{"a"},
+		Env:       map[string]string{"CGO_ENABLED": "1", "FOO": "bar"},
+		Hooks:     GoBuildHooks{Pre: []HookStep{{Command: "echo"}}},
+		Targets: GoBuildTargets{Overrides: map[string]GoBuildTarget{
+			"cmd/server": {
+				Platforms: []string{"linux/amd64"},
+				Flags:     []string{"-trimpath"},
+				Tags:      []string{"netgo"},
+				Env:       map[string]string{"CGO_ENABLED": "0"},
+			},
+		}},
+	}
+
+	got := b.ForTarget("cmd/server")
+	want := GoBuild{
+		Platforms: []string{"linux/amd64"},
+		Flags:     []string{"-v", "-trimpath"},
+		LDFlags:   []string{"-s"},
+		GCFlags:   []string{},
+		Tags:      []string{"a", "netgo"},
+		Env:       map[string]string{"CGO_ENABLED": "0", "FOO": "bar"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ForTarget() = %+v, want %+v", got, want)
+	}
+
+	got = b.ForTarget("cmd/cli")
+	if !reflect.DeepEqual(got.Platforms, b.Platforms) || !reflect.DeepEqual(got.Env, b.Env) {
+		t.Errorf("ForTarget() of target without overrides = %+v, want global settings", got)
+	}
+	// global settings must not be modified
+	if len(b.Flags) != 1 || b.Env["CGO_ENABLED"] != "1" {
+		t.Errorf("ForTarget() modified global settings: %+v", b)
+	}
+}
+
+func TestGoBuild_WithProfile(t *testing.T) {
+	b := GoBuild{
+		Flags:  []string{"-v"},
+		Env:    map[string]string{"FOO": "bar"},
+		Output: DefaultGoBuildOutput,
+		Profiles: map[string]GoBuildProfile{
+			"debug": {GCFlags: []string{"all=-N -l"}, Env: map[string]string{"FOO": "debug"}, Output: "bin/debug/{{.Name}}"},
+			"race":  {Flags: []string{"-race"}},
+		},
+	}
+
+	got, err := b.WithProfile("debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Profile != "debug" || got.Output != "bin/debug/{{.Name}}" || !reflect.DeepEqual(got.GCFlags, []string{"all=-N -l"}) ||
+		!reflect.DeepEqual(got.Env, map[string]string{"FOO": "debug"}) {
+		t.Errorf("WithProfile(debug) = %+v", got)
+	}
+	got, err = b.WithProfile("race")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Output != DefaultGoBuildOutput || !reflect.DeepEqual(got.Flags, []string{"-v", "-race"}) {
+		t.Errorf("WithProfile(race) = %+v", got)
+	}
+	// global settings must not be modified
+	if len(b.Flags) != 1 || b.Env["FOO"] != "bar" {
+		t.Errorf("WithProfile() modified global settings: %+v", b)
+	}
+	if _, err := b.WithProfile("release"); err == nil {
+		t.Errorf("WithProfile() of unknown profile should fail")
+	}
+	if got, err := b.WithProfile(""); err != nil || got.Profile != "" {
+		t.Errorf("WithProfile(\"\") = %+v, %v", got, err)
+	}
+}
+
+func TestGoBuild_Validate(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name    string
+		build   GoBuild
+		wantErr bool
+	}{
+		{"empty", GoBuild{}, false},
+		{"tags in flags", GoBuild{Flags: []string{"-tags=foo"}, Tags: []string{"bar"}}, true},
+		{"trimpath in flags", GoBuild{Flags: []string{"-trimpath"}, Trimpath: true}, true},
+		{"buildmode in flags", GoBuild{Flags: []string{"-buildmode", "pie"}, BuildMode: "pie"}, true},
+		{"cgo conflicts with env", GoBuild{CGO: &enabled, Env: map[string]string{"CGO_ENABLED": "0"}}, true},
+		{"cgo agrees with env", GoBuild{CGO: &disabled, Env: map[string]string{"CGO_ENABLED": "0"}}, false},
+		{"c-shared without cgo", GoBuild{CGO: &disabled, BuildMode: "c-shared"}, true},
+		{"static c-shared", GoBuild{Static: true, CGO: &enabled, BuildMode: "c-shared"}, true},
+		{"static pie", GoBuild{Static: true, BuildMode: "pie"}, false},
+		{"hook step without command", GoBuild{Hooks: GoBuildHooks{Post: []HookStep{{Name: "foo"}}}}, true},
+		{"hook step with invalid platform", GoBuild{Hooks: GoBuildHooks{Pre: []HookStep{{Command: "echo", When: &HookWhen{Platforms: []string{"linux/["}}}}}}, true},
+		{"hook step", GoBuild{Hooks: GoBuildHooks{Pre: []HookStep{{Command: "echo", When: &HookWhen{Platforms: []string{"linux/*"}}}}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.build.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHooks_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hooks   Hooks
+		wantErr bool
+	}{
+		{"empty", Hooks{}, false},
+		{"invalid timeout", Hooks{Timeout: "5"}, true},
+		{"invalid hook name", Hooks{Steps: map[string][]HookStep{"format": {{Command: "echo"}}}}, true},
+		{"go build hook", Hooks{Steps: map[string][]HookStep{"post-build": {{Command: "echo"}}}}, true},
+		{"step without command", Hooks{Steps: map[string][]HookStep{"pre-format": {{}}}}, true},
+		{"valid", Hooks{Timeout: "5m", Steps: map[string][]HookStep{"post-container-build": {{Command: "echo"}}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.hooks.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGoBuildTargets_ResolveOverrides(t *testing.T) {
+	targets := []string{"cmd/server", "cmd/cli", "tools/cli"}
+	tests := []struct {
+		keys    []string
+		want    []string
+		wantErr bool
+	}{
+		{keys: []string{"server", "tools/cli"}, want: []string{"cmd/server", "tools/cli"}},
+		{keys: []string{"cmd/cli"}, want: []string{"cmd/cli"}},
+		// typo of include
+		{keys: []string{"incldue"}, wantErr: true},
+		// cmd/cli or tools/cli
+		{keys: []string{"cli"}, wantErr: true},
+		{keys: []string{"server", "cmd/server"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		ts := GoBuildTargets{Overrides: map[string]GoBuildTarget{}}
+		for _, k := range tt.keys {
+			ts.Overrides[k] = GoBuildTarget{}
+		}
+		err := ts.ResolveOverrides(targets)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ResolveOverrides() of %v error = %v, wantErr %v", tt.keys, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		got := []string{}
+		for k := range ts.Overrides {
+			got = append(got, k)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ResolveOverrides() of %v = %v, want %v", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestGoBuildTargets_UnmarshalJSON(t *testing.T) {
+	data := []byte(`
+go:
+  build:
+    targets:
+      include: ["cmd/*", "tools/..."]
+      exclude: ["cmd/internal-*"]
+      server:
+        platforms: [linux/amd64]
+`)
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := GoBuildTargets{
+		Include: []string{"cmd/*", "tools/..."},
+		Exclude: []string{"cmd/internal-*"},
+		Overrides: map[string]GoBuildTarget{
+			"server": {Platforms: []string{"linux/amd64"}},
+		},
+	}
+	if !reflect.DeepEqual(cfg.Go.Build.Targets, want) {
+		t.Errorf("UnmarshalJSON() = %+v, want %+v", cfg.Go.Build.Targets, want)
+	}
+}
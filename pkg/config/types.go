@@ -1,5 +1,10 @@
 package config
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // Config is the unmarshalled representation of the configuration file
 type Config struct {
 	// Version is the project version, defaults to "1" (backwards compatibility)
@@ -10,6 +15,42 @@ type Config struct {
 
 	// container config
 	Container Container `json:"container,omitempty"`
+
+	// Hooks config of commands
+	Hooks Hooks `json:"hooks,omitempty"`
+
+	// Env config of all external commands
+	Env Env `json:"env,omitempty"`
+}
+
+// Env configures env of external commands run by make-rules, e.g. go,
+// docker and hooks. Values may refer to env of lower precedence by ${VAR}.
+// The precedence from low to high is: defaults (GO111MODULE=on), process
+// env, Files in order, Vars, Commands of the running command and the --env
+// flag.
+type Env struct {
+	// Files are dotenv files of KEY=VALUE lines loaded in order, relative
+	// path is related to workspace. A missing file is skipped.
+	Files []string `json:"files,omitempty"`
+	// Vars are env of all commands
+	Vars map[string]string `json:"vars,omitempty"`
+	// Commands are env of commands keyed by command name, which is the name
+	// used by hooks, e.g. build, mod-update, container-build
+	Commands map[string]map[string]string `json:"commands,omitempty"`
+}
+
+// Hooks configures hooks run before and after commands. Hooks are named
+// pre-<command> and post-<command>, e.g. pre-format, post-container-build.
+// go build runs its own hooks configured in go.build.
+type Hooks struct {
+	// Dir contains hook files named by hooks, relative path is related to
+	// workspace
+	Dir string `json:"dir,omitempty"`
+	// Timeout is the maximum duration of one hook, e.g. 5m, there is no
+	// timeout by default
+	Timeout string `json:"timeout,omitempty"`
+	// Steps are declarative hook steps keyed by hook name
+	Steps map[string][]HookStep `json:"steps,omitempty"`
 }
 
 type Go struct {
@@ -25,14 +66,229 @@ type GoTest struct {
 }
 
 type GoBuild struct {
+	// Platforms are os/arch or os/arch/variant supported by go, e.g.
+	// linux/arm/v7, or groups: all, first-class, common, all-<os>
 	Platforms      []string `json:"platforms,omitempty"`
 	OnBuildImage   string   `json:"onBuildImage,omitempty"`
 	GlobalHooksDir string   `json:"globalHooksDir,omitempty"`
-	Flags          []string `json:"flags,omitempty"`
-	LDFlags        []string `json:"ldflags,omitempty"`
-	GCFlags        []string `json:"gcflags,omitempty"`
+	// HookTimeout is the maximum duration of one hook, e.g. 5m, there is no
+	// timeout by default
+	HookTimeout string `json:"hookTimeout,omitempty"`
+	// Hooks are steps run once before and after all builds, after hooks in
+	// GlobalHooksDir
+	Hooks GoBuildHooks `json:"hooks,omitempty"`
+	Flags []string     `json:"flags,omitempty"`
+	// LDFlags are go templates rendered with version.Info, e.g.
+	// "-X main.commit={{.GitCommit}}"
+	LDFlags []string `json:"ldflags,omitempty"`
+	GCFlags []string `json:"gcflags,omitempty"`
+	// Parallelism is the maximum number of (target, platform) builds running
+	// at the same time, defaults to 1
+	Parallelism int `json:"parallelism,omitempty"`
+	// BuildDate decides the build date injected by ldflags, "now" (default)
+	// or "commit" which uses the committer time of HEAD, so that the version
+	// ldflags are deterministic and the build cache can hit.
+	BuildDate string `json:"buildDate,omitempty"`
+	// Output is the go template of binary output path, relative path is
+	// related to workspace. Available variables are .Workspace, .OS, .Arch,
+	// .Variant, .Name, .Version, .Profile and .Ext
+	Output string `json:"output,omitempty"`
+	// Tags are go build tags passed by -tags
+	Tags []string `json:"tags,omitempty"`
+	// CGO sets CGO_ENABLED, leave it empty to use the go default
+	CGO *bool `json:"cgo,omitempty"`
+	// Trimpath passes -trimpath to go build
+	Trimpath bool `json:"trimpath,omitempty"`
+	// BuildMode is passed by -buildmode
+	BuildMode string `json:"buildmode,omitempty"`
+	// Static builds statically linked binaries. It implies CGO_ENABLED=0
+	// unless cgo is enabled explicitly, in which case the external linker
+	// is used with -static
+	Static bool `json:"static,omitempty"`
+	// Env are extra environment variables of go build
+	Env map[string]string `json:"env,omitempty"`
+	// Reproducible makes builds of the same commit produce identical
+	// binaries. The build date is taken from SOURCE_DATE_EPOCH or the
+	// committer time of HEAD, -trimpath and -buildid= are forced, CGO is
+	// disabled unless it is set explicitly, host GOFLAGS, GOEXPERIMENT and
+	// microarchitecture env like GOAMD64 are cleared unless they are set in
+	// Env, and a <output>.sha256 checksum file is written for every binary.
+	Reproducible bool `json:"reproducible,omitempty"`
+	// SBOM writes CycloneDX (<output>.cdx.json) and SPDX (<output>.spdx.json)
+	// SBOMs next to every binary, generated from the build info embedded by
+	// go and the version info of the build.
+	SBOM bool `json:"sbom,omitempty"`
+	// VersionPackage is the package receiving version variables by -X,
+	// defaults to github.com/zoumo/make-rules/version
+	VersionPackage string `json:"versionPackage,omitempty"`
+	// VersionVariables maps version variables (buildDate, gitVersion,
+	// gitCommit, gitRemote, gitTreeState) to symbols. A symbol without "." is
+	// a variable in VersionPackage, otherwise it is a full symbol like
+	// main.commit. An empty symbol disables injecting the variable.
+	VersionVariables map[string]string `json:"versionVariables,omitempty"`
+	// Archives configures release archives created by go package
+	Archives GoArchives `json:"archives,omitempty"`
+	// Targets configures target discovery and overrides settings above for
+	// each target
+	Targets GoBuildTargets `json:"targets,omitempty"`
+	// Profile is the name of profile in Profiles applied by default
+	Profile string `json:"profile,omitempty"`
+	// Profiles are named settings applied on top of the settings above,
+	// e.g. release, debug and race
+	Profiles map[string]GoBuildProfile `json:"profiles,omitempty"`
 }
 
+// GoBuildTargets configures how targets are discovered and their settings.
+// In config file, include and exclude are lists of globs, any other key is
+// the full path of a target relative to workspace, or its base name if it is
+// not ambiguous, e.g. "cmd/server" or "server", with its GoBuildTarget
+// settings:
+//
+//	targets:
+//	  include: ["cmd/*", "tools/..."]
+//	  exclude: ["cmd/internal-*"]
+//	  cmd/server:
+//	    platforms: [linux/amd64]
+type GoBuildTargets struct {
+	// Include are globs of target dirs relative to workspace, every
+	// "package main" matching one of them is a target. A glob ending with
+	// "/..." matches the dir and all its sub dirs, "." opts in the main
+	// package in module root. Defaults to ["cmd/*"]
+	Include []string
+	// Exclude are globs of target dirs which are not targets
+	Exclude []string
+	// Overrides are settings of each target keyed by target path or base
+	// name, keys are resolved to target paths by ResolveOverrides
+	Overrides map[string]GoBuildTarget
+}
+
+func (t *GoBuildTargets) UnmarshalJSON(data []byte) error {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "include":
+			err = json.Unmarshal(v, &t.Include)
+		case "exclude":
+			err = json.Unmarshal(v, &t.Exclude)
+		default:
+			target := GoBuildTarget{}
+			err = json.Unmarshal(v, &target)
+			if t.Overrides == nil {
+				t.Overrides = map[string]GoBuildTarget{}
+			}
+			t.Overrides[k] = target
+		}
+		if err != nil {
+			return fmt.Errorf("invalid targets.%s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+func (t GoBuildTargets) MarshalJSON() ([]byte, error) {
+	raw := map[string]any{}
+	for k, v := range t.Overrides {
+		raw[k] = v
+	}
+	if len(t.Include) > 0 {
+		raw["include"] = t.Include
+	}
+	if len(t.Exclude) > 0 {
+		raw["exclude"] = t.Exclude
+	}
+	return json.Marshal(raw)
+}
+
+// GoArchives configures release archives, one archive is created for each
+// platform containing all binaries built for it.
+type GoArchives struct {
+	// Dir is the directory archives are written to, relative path is related
+	// to workspace, defaults to dist
+	Dir string `json:"dir,omitempty"`
+	// Name is the go template of archive name without extension. Available
+	// variables are .Project, .Version, .OS, .Arch and .Variant
+	Name string `json:"name,omitempty"`
+	// Format is the archive format, tar.gz or zip. It defaults to zip for
+	// windows and tar.gz for other OS
+	Format string `json:"format,omitempty"`
+	// Files are extra files bundled into every archive, e.g. LICENSE
+	Files []string `json:"files,omitempty"`
+}
+
+const (
+	ArchiveTarGz = "tar.gz"
+	ArchiveZip   = "zip"
+)
+
+// GoBuildTarget is the go build settings of one target.
+// Platforms replaces the global platforms, Flags, LDFlags, GCFlags and Tags
+// are appended to the global ones, Env is merged into the global env.
+type GoBuildTarget struct {
+	Platforms []string          `json:"platforms,omitempty"`
+	Flags     []string          `json:"flags,omitempty"`
+	LDFlags   []string          `json:"ldflags,omitempty"`
+	GCFlags   []string          `json:"gcflags,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	// Hooks are steps run before and after every build of target, after
+	// hooks in target dir
+	Hooks GoBuildHooks `json:"hooks,omitempty"`
+}
+
+// GoBuildProfile is a named set of go build settings. Flags, LDFlags, GCFlags
+// and Tags are appended to the global ones, Env is merged into the global
+// env and Output replaces the global output.
+type GoBuildProfile struct {
+	Flags   []string          `json:"flags,omitempty"`
+	LDFlags []string          `json:"ldflags,omitempty"`
+	GCFlags []string          `json:"gcflags,omitempty"`
+	Tags    []string          `json:"tags,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Output  string            `json:"output,omitempty"`
+}
+
+// GoBuildHooks are declarative hook steps
+type GoBuildHooks struct {
+	Pre  []HookStep `json:"pre,omitempty"`
+	Post []HookStep `json:"post,omitempty"`
+}
+
+// HookStep is a command run as hook, it gets the same env as hook files.
+// ${VAR} in Args and Env values is expanded with hook env and os env.
+type HookStep struct {
+	// Name is shown in logs, defaults to Command
+	Name    string   `json:"name,omitempty"`
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// Env are extra environment variables of command
+	Env map[string]string `json:"env,omitempty"`
+	// Dir is the working dir of command, relative path is related to
+	// workspace, defaults to workspace
+	Dir string `json:"dir,omitempty"`
+	// ContinueOnError logs the error of command instead of failing the build
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+	// When are the conditions of running the step, all of them must be met
+	When *HookWhen `json:"when,omitempty"`
+}
+
+// HookWhen are the conditions of running a hook step
+type HookWhen struct {
+	// Platforms are globs of platforms, e.g. linux/*. The step runs if one of
+	// the build platforms matches
+	Platforms []string `json:"platforms,omitempty"`
+	// CleanTree runs the step only if the git tree is clean
+	CleanTree bool `json:"cleanTree,omitempty"`
+}
+
+const (
+	BuildDateNow    = "now"
+	BuildDateCommit = "commit"
+)
+
 type GoMod struct {
 	Require []GoModRequire `json:"require,omitempty"`
 	Replace []GoModReplace `json:"replace,omitempty"`
@@ -64,4 +320,9 @@ type Container struct {
 	Registries  []string `json:"registries,omitempty"`
 	ImagePrefix string   `json:"imagePrefix,omitempty"`
 	ImageSuffix string   `json:"imageSuffix,omitempty"`
+	// Platform is the platform of images in os/arch or os/arch/variant, it
+	// is passed to docker build by --platform if set. The binary built by go
+	// build for it is passed to Dockerfile, it defaults to linux on the host
+	// arch.
+	Platform string `json:"platform,omitempty"`
 }
@@ -0,0 +1,198 @@
+package environ
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+// Sources of env, from low to high precedence. Dotenv files are sources of
+// their own path.
+const (
+	SourceDefault = "default"
+	SourceProcess = "process"
+	SourceVars    = "env.vars"
+	// SourceCommandPrefix is followed by command name, e.g.
+	// env.commands.build
+	SourceCommandPrefix = "env.commands."
+	SourceFlag          = "--env"
+)
+
+// flagEnv is set by the global --env flag
+var flagEnv []string
+
+// InitFlags registers the global --env flag into fs
+func InitFlags(fs *pflag.FlagSet) {
+	fs.StringArrayVar(&flagEnv, "env", flagEnv, "set env of all external commands, e.g. --env GOPROXY=direct, it can be repeated")
+}
+
+// FlagEnv returns KEY=VALUE pairs set by --env
+func FlagEnv() []string {
+	return flagEnv
+}
+
+// Var is an env var with the source setting it
+type Var struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Env is the resolved env
+type Env struct {
+	vars map[string]Var
+}
+
+func newEnv() *Env {
+	return &Env{vars: map[string]Var{}}
+}
+
+func (e *Env) set(name, value, source string) {
+	e.vars[name] = Var{Name: name, Value: value, Source: source}
+}
+
+// Get returns the value of env name
+func (e *Env) Get(name string) (string, bool) {
+	v, ok := e.vars[name]
+	return v.Value, ok
+}
+
+// Expand replaces ${VAR} and $VAR in s by env, unset vars are replaced by
+// empty string
+func (e *Env) Expand(s string) string {
+	return os.Expand(s, func(name string) string {
+		v, _ := e.Get(name)
+		return v
+	})
+}
+
+// Map returns env in a map
+func (e *Env) Map() map[string]string {
+	m := make(map[string]string, len(e.vars))
+	for k, v := range e.vars {
+		m[k] = v.Value
+	}
+	return m
+}
+
+// Vars returns all vars sorted by name
+func (e *Env) Vars() []Var {
+	vars := make([]Var, 0, len(e.vars))
+	for _, v := range e.vars {
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	return vars
+}
+
+// apply sets vars from source, their values are expanded by env before
+// they are applied, so a var can refer to its value of lower precedence,
+// e.g. PATH: ${PATH}:/opt/bin
+func (e *Env) apply(vars map[string]string, source string) {
+	expanded := make(map[string]string, len(vars))
+	for k, v := range vars {
+		expanded[k] = e.Expand(v)
+	}
+	for k, v := range expanded {
+		e.set(k, v, source)
+	}
+}
+
+// Resolve returns env of command in workspace, command is the name used by
+// hooks, e.g. build, mod-update. The precedence from low to high is:
+//
+//  1. runner.DefaultEnv, e.g. GO111MODULE=on
+//  2. process env
+//  3. dotenv files in cfg.Files, in order
+//  4. cfg.Vars
+//  5. cfg.Commands[command]
+//  6. flags of KEY=VALUE, e.g. set by --env
+func Resolve(cfg config.Env, workspace, command string, environ, flags []string) (*Env, error) {
+	e := newEnv()
+	for k, v := range runner.DefaultEnv {
+		e.set(k, v, SourceDefault)
+	}
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			e.set(k, v, SourceProcess)
+		}
+	}
+	for _, file := range cfg.Files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(workspace, file)
+		}
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := e.loadDotenv(string(data), file); err != nil {
+			return nil, fmt.Errorf("invalid env file %s: %w", file, err)
+		}
+	}
+	e.apply(cfg.Vars, SourceVars)
+	e.apply(cfg.Commands[command], SourceCommandPrefix+command)
+
+	fromFlags := map[string]string{}
+	for _, kv := range flags {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid env %q, it must be KEY=VALUE", kv)
+		}
+		fromFlags[k] = v
+	}
+	e.apply(fromFlags, SourceFlag)
+	return e, nil
+}
+
+// loadDotenv sets vars of dotenv data in order, a line is KEY=VALUE with an
+// optional "export " prefix. Values in single quotes are literal, others
+// are expanded by env including vars of previous lines. Empty lines and
+// lines starting with # are skipped.
+func (e *Env) loadDotenv(data, source string) error {
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" || strings.ContainsAny(k, " \t") {
+			return fmt.Errorf("line %d: %q is not KEY=VALUE", i+1, line)
+		}
+		v = strings.TrimSpace(v)
+		if v != "" && (v[0] == '\'' || v[0] == '"') {
+			quote := v[0]
+			end := strings.IndexByte(v[1:], quote) + 1
+			if end == 0 {
+				return fmt.Errorf("line %d: unterminated quoted value", i+1)
+			}
+			// only an inline comment is allowed after the quoted value
+			if rest := strings.TrimSpace(v[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return fmt.Errorf("line %d: unexpected %q after quoted value", i+1, rest)
+			}
+			v = v[1:end]
+			if quote == '"' {
+				v = e.Expand(strings.ReplaceAll(v, `\n`, "\n"))
+			}
+		} else {
+			// strip inline comment
+			if idx := strings.Index(v, " #"); idx >= 0 {
+				v = strings.TrimSpace(v[:idx])
+			}
+			v = e.Expand(v)
+		}
+		e.set(k, v, source)
+	}
+	return nil
+}
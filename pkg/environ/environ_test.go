@@ -0,0 +1,99 @@
+package environ
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zoumo/make-rules/pkg/config"
+)
+
+func TestResolve(t *testing.T) {
+	workspace := t.TempDir()
+	dotenv := `# comment
+export GOPRIVATE="corp.example.com/*" # private repos
+GOPROXY=https://proxy.example.com # inline comment
+LITERAL='$HOME'
+MULTI="a\nb"
+EMPTY=
+`
+	if err := os.WriteFile(filepath.Join(workspace, ".env"), []byte(dotenv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Env{
+		Files: []string{".env", "missing.env"},
+		Vars: map[string]string{
+			"PATH":    "${PATH}:/opt/bin",
+			"GOFLAGS": "-mod=mod",
+		},
+		Commands: map[string]map[string]string{
+			"build": {"GOFLAGS": "$GOFLAGS -trimpath", "CGO_ENABLED": "0"},
+			"test":  {"CGO_ENABLED": "1"},
+		},
+	}
+	environ := []string{"PATH=/bin", "HOME=/root", "GOPROXY=direct", "CGO_ENABLED=1"}
+
+	e, err := Resolve(cfg, workspace, "build", environ, []string{"CGO_ENABLED=2", "FOO=${HOME}/foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"GO111MODULE": "on",
+		"GOPRIVATE":   "corp.example.com/*",
+		"GOPROXY":     "https://proxy.example.com",
+		"LITERAL":     "$HOME",
+		"MULTI":       "a\nb",
+		"EMPTY":       "",
+		"PATH":        "/bin:/opt/bin",
+		"HOME":        "/root",
+		"GOFLAGS":     "-mod=mod -trimpath",
+		"CGO_ENABLED": "2",
+		"FOO":         "/root/foo",
+	}
+	if got := e.Map(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve() = %v, want %v", got, want)
+	}
+
+	sources := map[string]string{}
+	for _, v := range e.Vars() {
+		sources[v.Name] = v.Source
+	}
+	wantSources := map[string]string{
+		"GO111MODULE": SourceDefault,
+		"HOME":        SourceProcess,
+		"GOPROXY":     filepath.Join(workspace, ".env"),
+		"PATH":        SourceVars,
+		"GOFLAGS":     SourceCommandPrefix + "build",
+		"CGO_ENABLED": SourceFlag,
+	}
+	for name, want := range wantSources {
+		if sources[name] != want {
+			t.Errorf("source of %s = %q, want %q", name, sources[name], want)
+		}
+	}
+
+	// process env overrides defaults
+	e, err = Resolve(config.Env{}, workspace, "build", []string{"GO111MODULE=off"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := e.Get("GO111MODULE"); v != "off" {
+		t.Errorf("GO111MODULE = %q, want off", v)
+	}
+}
+
+func TestResolve_Invalid(t *testing.T) {
+	workspace := t.TempDir()
+	if _, err := Resolve(config.Env{}, workspace, "build", nil, []string{"FOO"}); err == nil {
+		t.Error("Resolve() with --env FOO succeeded, want error")
+	}
+	for _, dotenv := range []string{"FOO BAR=1", "FOO", `FOO="bar`, `FOO="bar" baz`} {
+		if err := os.WriteFile(filepath.Join(workspace, ".env"), []byte(dotenv), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Resolve(config.Env{Files: []string{".env"}}, workspace, "build", nil, nil); err == nil {
+			t.Errorf("Resolve() with dotenv %q succeeded, want error", dotenv)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package golang
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// executable is the symbol table and memory image of a go binary in any of
+// ELF, Mach-O and PE format, read without running the binary, so binaries
+// built for foreign platforms can be read too
+type executable struct {
+	ptrSize int
+	order   binary.ByteOrder
+	// symbols are addresses of symbols keyed by name
+	symbols map[string]uint64
+	// sections are the memory image of binary
+	sections []section
+	closer   io.Closer
+}
+
+// section is a range of memory, the ReaderAt of zero filled memory like bss
+// is nil
+type section struct {
+	addr uint64
+	size uint64
+	io.ReaderAt
+}
+
+// ReadStringVars reads the initial values of go string variables, e.g. the
+// ones injected by -ldflags "-X symbol=value", from the symbol table and the
+// data section of binary file. Symbols not found are omitted in the result.
+// An error is returned if the binary has no symbol table, e.g. it is built
+// with -ldflags=-s.
+func ReadStringVars(file string, symbols []string) (map[string]string, error) {
+	exe, err := openExecutable(file)
+	if err != nil {
+		return nil, err
+	}
+	defer exe.closer.Close()
+	vars := map[string]string{}
+	for _, sym := range symbols {
+		addr, ok := exe.symbols[sym]
+		if !ok {
+			continue
+		}
+		// a go string is a (pointer, length) pair
+		header, err := exe.read(addr, 2*exe.ptrSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", sym, err)
+		}
+		ptr, length := exe.uintptr(header[:exe.ptrSize]), exe.uintptr(header[exe.ptrSize:])
+		if length == 0 {
+			vars[sym] = ""
+			continue
+		}
+		data, err := exe.read(ptr, int(length))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", sym, err)
+		}
+		vars[sym] = string(data)
+	}
+	return vars, nil
+}
+
+// ParseLDFlagsX returns values of "-X symbol=value" in ldflags recorded in
+// go build info
+func ParseLDFlagsX(ldflags string) map[string]string {
+	vars := map[string]string{}
+	args := splitQuoted(ldflags)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-X" || arg == "--X":
+			if i+1 >= len(args) {
+				continue
+			}
+			i++
+			arg = args[i]
+		case strings.HasPrefix(arg, "-X="):
+			arg = strings.TrimPrefix(arg, "-X=")
+		case strings.HasPrefix(arg, "--X="):
+			arg = strings.TrimPrefix(arg, "--X=")
+		default:
+			continue
+		}
+		if kv := strings.SplitN(arg, "=", 2); len(kv) == 2 {
+			vars[kv[0]] = kv[1]
+		}
+	}
+	return vars
+}
+
+// splitQuoted splits s by spaces, single or double quoted parts are kept
+// together as go build does for -ldflags
+func splitQuoted(s string) []string {
+	args := []string{}
+	cur := strings.Builder{}
+	inArg := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+func (e *executable) uintptr(b []byte) uint64 {
+	if e.ptrSize == 8 {
+		return e.order.Uint64(b)
+	}
+	return uint64(e.order.Uint32(b))
+}
+
+// read reads n bytes at virtual address addr
+func (e *executable) read(addr uint64, n int) ([]byte, error) {
+	for _, s := range e.sections {
+		if addr < s.addr || addr+uint64(n) > s.addr+s.size {
+			continue
+		}
+		buf := make([]byte, n)
+		if s.ReaderAt == nil {
+			return buf, nil
+		}
+		if _, err := s.ReadAt(buf, int64(addr-s.addr)); err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("address %#x is not in any section", addr)
+}
+
+var errNoSymbols = errors.New("no symbol table, the binary may be stripped by -ldflags=-s")
+
+func openExecutable(file string) (*executable, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	var exe *executable
+	switch {
+	case string(magic) == elf.ELFMAG:
+		exe, err = openELF(file)
+	case string(magic[:2]) == "MZ":
+		exe, err = openPE(file)
+	default:
+		exe, err = openMachO(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return exe, nil
+}
+
+func openELF(file string) (*executable, error) {
+	f, err := elf.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	syms, err := f.Symbols()
+	if err != nil {
+		f.Close()
+		if errors.Is(err, elf.ErrNoSymbols) {
+			return nil, errNoSymbols
+		}
+		return nil, err
+	}
+	exe := &executable{ptrSize: 4, order: f.ByteOrder, symbols: map[string]uint64{}, closer: f}
+	if f.Class == elf.ELFCLASS64 {
+		exe.ptrSize = 8
+	}
+	for _, s := range syms {
+		exe.symbols[s.Name] = s.Value
+	}
+	for _, s := range f.Sections {
+		switch {
+		case s.Flags&elf.SHF_ALLOC == 0:
+		case s.Type == elf.SHT_NOBITS:
+			exe.sections = append(exe.sections, section{s.Addr, s.Size, nil})
+		default:
+			exe.sections = append(exe.sections, section{s.Addr, s.Size, s})
+		}
+	}
+	return exe, nil
+}
+
+func openMachO(file string) (*executable, error) {
+	f, err := macho.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	if f.Symtab == nil {
+		f.Close()
+		return nil, errNoSymbols
+	}
+	exe := &executable{ptrSize: 4, order: f.ByteOrder, symbols: map[string]uint64{}, closer: f}
+	if f.Magic == macho.Magic64 {
+		exe.ptrSize = 8
+	}
+	for _, s := range f.Symtab.Syms {
+		// go symbols of darwin are prefixed with "_"
+		exe.symbols[strings.TrimPrefix(s.Name, "_")] = s.Value
+	}
+	for _, s := range f.Sections {
+		if s.Flags&0xff == 0x1 { // S_ZEROFILL
+			exe.sections = append(exe.sections, section{s.Addr, s.Size, nil})
+			continue
+		}
+		exe.sections = append(exe.sections, section{s.Addr, s.Size, s})
+	}
+	return exe, nil
+}
+
+func openPE(file string) (*executable, error) {
+	f, err := pe.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Symbols) == 0 {
+		f.Close()
+		return nil, errNoSymbols
+	}
+	exe := &executable{ptrSize: 4, order: binary.LittleEndian, symbols: map[string]uint64{}, closer: f}
+	var imageBase uint64
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imageBase = uint64(h.ImageBase)
+	case *pe.OptionalHeader64:
+		imageBase = h.ImageBase
+		exe.ptrSize = 8
+	}
+	for _, s := range f.Symbols {
+		// value of symbol is the offset in its section
+		if s.SectionNumber <= 0 || int(s.SectionNumber) > len(f.Sections) {
+			continue
+		}
+		sec := f.Sections[s.SectionNumber-1]
+		exe.symbols[s.Name] = imageBase + uint64(sec.VirtualAddress) + uint64(s.Value)
+	}
+	for _, s := range f.Sections {
+		// memory beyond the raw data of section is zero filled
+		addr, raw, virtual := imageBase+uint64(s.VirtualAddress), uint64(s.Size), uint64(s.VirtualSize)
+		if raw > virtual {
+			raw = virtual
+		}
+		exe.sections = append(exe.sections, section{addr, raw, s})
+		if virtual > raw {
+			exe.sections = append(exe.sections, section{addr + raw, virtual - raw, nil})
+		}
+	}
+	return exe, nil
+}
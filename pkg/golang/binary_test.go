@@ -0,0 +1,54 @@
+package golang
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadStringVars(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds binaries for several platforms")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/inspect\n\ngo 1.21\n",
+		"main.go": "package main\n\nvar commit, empty string\n\nfunc main() { println(commit, empty) }\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	symbols := []string{"main.commit", "main.empty", "main.missing"}
+	want := map[string]string{"main.commit": "abc def", "main.empty": ""}
+	for _, p := range [][2]string{{"linux", "arm64"}, {"linux", "386"}, {"darwin", "arm64"}, {"windows", "amd64"}} {
+		t.Run(p[0]+"/"+p[1], func(t *testing.T) {
+			output := filepath.Join(dir, p[0]+"_"+p[1])
+			cmd := exec.Command("go", "build", "-trimpath", "-o", output, "-ldflags", "-X 'main.commit=abc def'", ".")
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "GOOS="+p[0], "GOARCH="+p[1], "CGO_ENABLED=0", "GOFLAGS=")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("go build: %v\n%s", err, out)
+			}
+			got, err := ReadStringVars(output, symbols)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadStringVars() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseLDFlagsX(t *testing.T) {
+	got := ParseLDFlagsX(`-s -w -X main.a=1 -X 'main.b=x y' -X=main.c=2 -X "main.d=a=b"`)
+	want := map[string]string{"main.a": "1", "main.b": "x y", "main.c": "2", "main.d": "a=b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLDFlagsX() = %v, want %v", got, want)
+	}
+}
@@ -25,7 +25,7 @@ const (
 )
 
 type GomodHelper struct {
-	goRunner     *runner.Runner
+	goRunner     runner.Executor
 	modfile      string
 	logger       log.Logger
 	downloadTemp string
@@ -34,13 +34,19 @@ type GomodHelper struct {
 }
 
 func NewGomodHelper(modfile string, logger log.Logger) *GomodHelper {
-	version, err := getGoVersion()
+	return NewGomodHelperWithExecutor(modfile, logger, runner.NewRunner("go"))
+}
+
+// NewGomodHelperWithExecutor returns a GomodHelper running go commands by
+// goCmd in the dir of modfile
+func NewGomodHelperWithExecutor(modfile string, logger log.Logger, goCmd runner.Executor) *GomodHelper {
+	version, err := getGoVersion(goCmd)
 	if err != nil {
 		panic(fmt.Sprintf("failed to get go version: %v", err))
 	}
 	g := &GomodHelper{
 		modfile:   modfile,
-		goRunner:  runner.NewRunner("go").WithDir(path.Dir(modfile)),
+		goRunner:  goCmd.WithDir(path.Dir(modfile)),
 		logger:    logger,
 		pinned:    goset.NewSet(),
 		goVersion: version,
@@ -56,18 +62,21 @@ func (g *GomodHelper) Require(path, version string, skipDeps bool) error {
 		return err
 	}
 
-	// copy modfile to temp
-	modfile := backupGomod(mod.GoMod)
-	if modfile == "" {
+	if mod.GoMod == "" {
 		return fmt.Errorf("module %s version %s does not have a go.mod file", path, version)
 	}
+	// copy modfile to temp
+	modfile, err := backupGomod(mod.GoMod)
+	if err != nil {
+		return err
+	}
 
 	g.logger.Info("download required package/go.mod to temp dir", "package", path, "source", mod.GoMod, "target", modfile)
 
 	// replace version with mod version
 	version = mod.Version
 
-	newgomod := NewGomodHelper(modfile, g.logger)
+	newgomod := NewGomodHelperWithExecutor(modfile, g.logger, g.goRunner)
 
 	// kubernetes imports module in its staging path
 	// we must replace these module firstly to avoid error occurring
@@ -222,12 +231,16 @@ func (g *GomodHelper) Format() error {
 		buf.WriteString(")\n")
 	}
 
+	if runner.DryRun() {
+		g.logger.Info("Dry run, go.mod is not formatted", "file", g.modfile)
+		return nil
+	}
 	// overwrite go.mod
 	return ioutil.WriteFile(g.modfile, buf.Bytes(), 0644)
 }
 
 func (g *GomodHelper) ParseMod() (*GoMod, error) {
-	data, err := g.goRunner.RunOutput("mod", "edit", "-json")
+	data, err := g.goRunner.ReadOnly().RunOutput("mod", "edit", "-json")
 	if err != nil {
 		return nil, err
 	}
@@ -240,26 +253,36 @@ func (g *GomodHelper) ParseMod() (*GoMod, error) {
 	return &gomod, nil
 }
 
-func (g *GomodHelper) ParseListMod() ([]ListModule, error) {
-	// backup go.mod because go list will change go.mod
-	file := backupGomod(g.modfile)
-
-	if g.goVersion.Compare(go1160) >= 0 {
-		// go1.16.0
-		// we must run go mod tidy before go list if go version is
-		// greater than go1.16.0, otherwise it will fail.
-		err := g.ModTidy()
-		if err != nil {
-			return nil, err
+func (g *GomodHelper) ParseListMod() (ret []ListModule, err error) {
+	// go.mod is left as is in dry run, go mod tidy does not run either
+	if !runner.DryRun() {
+		// backup go.mod because go list will change go.mod
+		file, berr := backupGomod(g.modfile)
+		if berr != nil {
+			return nil, berr
+		}
+		defer func() {
+			if rerr := restoreGomod(g.modfile, file); rerr != nil && err == nil {
+				err = rerr
+			}
+		}()
+
+		if g.goVersion.Compare(go1160) >= 0 {
+			// go1.16.0
+			// we must run go mod tidy before go list if go version is
+			// greater than go1.16.0, otherwise it will fail.
+			if err := g.ModTidy(); err != nil {
+				return nil, err
+			}
 		}
 	}
-	out, err := g.goRunner.RunOutput("list", "-m", "-json", "all")
+	out, err := g.goRunner.ReadOnly().RunOutput("list", "-m", "-json", "all")
 	if err != nil {
 		return nil, err
 	}
 
 	decoder := json.NewDecoder(bytes.NewBuffer(out))
-	ret := []ListModule{}
+	ret = []ListModule{}
 	for {
 		var m ListModule
 		if err := decoder.Decode(&m); err == io.EOF {
@@ -273,8 +296,6 @@ func (g *GomodHelper) ParseListMod() ([]ListModule, error) {
 		}
 		ret = append(ret, m)
 	}
-
-	restoreGomod(g.modfile, file)
 	return ret, nil
 }
 
@@ -283,7 +304,8 @@ func (g *GomodHelper) ModDownload(path, version string) (*DownloadModule, error)
 		temp, _ := ioutil.TempDir("", "gomod.*")
 		g.downloadTemp = temp
 	}
-	run := g.goRunner.WithDir(g.downloadTemp)
+	// download only writes the module cache, its version is needed to edit go.mod
+	run := g.goRunner.WithDir(g.downloadTemp).ReadOnly()
 	out, err := run.RunOutput("mod", "download", "-json", fmt.Sprintf("%s@%s", path, version))
 	if err != nil {
 		return nil, err
@@ -332,18 +354,28 @@ func (g *GomodHelper) edit(verb ModeEditVerb, oldPath, newPath, version string)
 	return nil
 }
 
-func backupGomod(modfile string) string {
-	dir, _ := ioutil.TempDir("", "gomod.*")
+func backupGomod(modfile string) (string, error) {
+	data, err := ioutil.ReadFile(modfile)
+	if err != nil {
+		return "", err
+	}
+	dir, err := ioutil.TempDir("", "gomod.*")
+	if err != nil {
+		return "", err
+	}
 	file := path.Join(dir, "go.mod")
-
-	data, _ := ioutil.ReadFile(modfile)
-	ioutil.WriteFile(file, data, 0644) //nolint:errcheck
-	return file
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		return "", err
+	}
+	return file, nil
 }
 
-func restoreGomod(modfile, tempfile string) {
-	data, _ := ioutil.ReadFile(tempfile)
-	ioutil.WriteFile(modfile, data, 0644) //nolint:errcheck
+func restoreGomod(modfile, tempfile string) error {
+	data, err := ioutil.ReadFile(tempfile)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(modfile, data, 0644)
 }
 
 func IsValidVersion(version string) bool {
@@ -0,0 +1,112 @@
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+// editCalls returns args of go mod edit -fmt run by goCmd
+func editCalls(goCmd *runner.Fake) []string {
+	edits := []string{}
+	for _, c := range goCmd.Calls() {
+		if len(c.Args) == 4 && c.Args[0] == "mod" && c.Args[1] == "edit" && c.Args[2] == "-fmt" {
+			edits = append(edits, c.Args[3])
+		}
+	}
+	return edits
+}
+
+func TestGomodHelper_Replace(t *testing.T) {
+	goCmd := runner.NewFake("go")
+	goCmd.On("version").Return("go version go1.22.0 linux/amd64\n", "", 0)
+	goCmd.On("mod", "download", "-json", "github.com/b/foo@master").Return(`{"Path":"github.com/b/foo","Version":"v1.2.1-0.20240101000000-abcdef123456"}`, "", 0)
+	goCmd.On("mod", "edit", "-fmt", "*")
+
+	g := NewGomodHelperWithExecutor("/src/proj/go.mod", log.Log, goCmd)
+	if err := g.Replace("github.com/a/foo", "github.com/b/foo", "master"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Replace("github.com/a/bar", "../bar", ""); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"-require=github.com/a/foo@v1.2.1-0.20240101000000-abcdef123456",
+		"-replace=github.com/a/foo=github.com/b/foo@v1.2.1-0.20240101000000-abcdef123456",
+		"-require=github.com/a/bar@v0.0.0",
+		"-replace=github.com/a/bar=../bar",
+	}
+	if got := editCalls(goCmd); !reflect.DeepEqual(got, want) {
+		t.Errorf("go mod edit = %v, want %v", got, want)
+	}
+	for _, c := range goCmd.Calls() {
+		if c.Args[0] == "mod" && c.Args[1] == "edit" && c.Dir != "/src/proj" {
+			t.Errorf("go mod edit runs in %q, want /src/proj", c.Dir)
+		}
+	}
+}
+
+func TestGomodHelper_Require(t *testing.T) {
+	gomod := filepath.Join(t.TempDir(), "foo.mod")
+	if err := os.WriteFile(gomod, []byte("module github.com/a/foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	goCmd := runner.NewFake("go")
+	goCmd.On("version").Return("go version go1.22.0 linux/amd64\n", "", 0)
+	goCmd.On("mod", "download", "-json", "github.com/a/foo@v1.0").Return(`{"Path":"github.com/a/foo","Version":"v1.0.0","GoMod":"`+gomod+`"}`, "", 0)
+	// go.mod of github.com/a/foo
+	goCmd.On("mod", "edit", "-json").Return(`{
+		"Module": {"Path": "github.com/a/foo"},
+		"Require": [{"Path": "github.com/c/dep", "Version": "v0.3.0"}, {"Path": "github.com/d/local", "Version": "v0.0.0"}],
+		"Replace": [{"Old": {"Path": "github.com/e/dep"}, "New": {"Path": "github.com/e/fork", "Version": "v2.0.0"}}]
+	}`, "", 0)
+	goCmd.On("mod", "edit", "-fmt", "*")
+
+	g := NewGomodHelperWithExecutor("/src/proj/go.mod", log.Log, goCmd)
+	if err := g.Require("github.com/a/foo", "v1.0", false); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"-require=github.com/c/dep@v0.3.0",
+		"-require=github.com/e/dep@v2.0.0",
+		"-require=github.com/a/foo@v1.0.0",
+		"-replace=github.com/a/foo=github.com/a/foo@v1.0.0",
+	}
+	if got := editCalls(goCmd); !reflect.DeepEqual(got, want) {
+		t.Errorf("go mod edit = %v, want %v", got, want)
+	}
+}
+
+func TestGomodHelper_ParseListMod_DryRun(t *testing.T) {
+	runner.SetDryRun(true)
+	defer runner.SetDryRun(false)
+
+	// go.mod does not exist, it must not be read or written in dry run
+	gomod := filepath.Join(t.TempDir(), "go.mod")
+	goCmd := runner.NewFake("go")
+	goCmd.On("version").Return("go version go1.22.0 linux/amd64\n", "", 0)
+	goCmd.On("list", "-m", "-json", "all").Return(`{"Path":"github.com/a/proj","Main":true}
+{"Path":"github.com/c/dep","Version":"v0.3.0"}`, "", 0)
+
+	g := NewGomodHelperWithExecutor(gomod, log.Log, goCmd)
+	mods, err := g.ParseListMod()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mods) != 1 || mods[0].Path != "github.com/c/dep" {
+		t.Errorf("ParseListMod() = %+v", mods)
+	}
+	for _, c := range goCmd.Calls() {
+		if c.Args[0] == "mod" && c.Args[1] == "tidy" {
+			t.Errorf("go mod tidy runs in dry run")
+		}
+	}
+	if _, err := os.Stat(gomod); !os.IsNotExist(err) {
+		t.Errorf("go.mod is written in dry run: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// OutputVars are variables used to render the go build output template
+type OutputVars struct {
+	// Workspace is the absolute path of workspace
+	Workspace string
+	// OS is the GOOS of target platform
+	OS string
+	// Arch is the GOARCH of target platform
+	Arch string
+	// Variant is the sub architecture of target platform, e.g. v7 of
+	// linux/arm/v7, it is empty if platform has no variant
+	Variant string
+	// Name is the binary name, it is the base name of target
+	Name string
+	// Version is the git version of build
+	Version string
+	// Profile is the name of go build profile, it is empty if no profile
+	// is selected
+	Profile string
+	// Ext is the executable extension of OS, e.g. ".exe" for windows
+	Ext string
+}
+
+// ExecutableExt returns the extension of executable file for goos
+func ExecutableExt(goos string) string {
+	switch goos {
+	case "windows":
+		return ".exe"
+	case "js", "wasip1":
+		return ".wasm"
+	}
+	return ""
+}
+
+// OutputTemplate renders the output path of go build
+type OutputTemplate struct {
+	tmpl *template.Template
+}
+
+// ParseOutputTemplate parses the output template text
+func ParseOutputTemplate(text string) (*OutputTemplate, error) {
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &OutputTemplate{tmpl: tmpl}, nil
+}
+
+// Execute renders the output path with vars, relative path is joined with
+// vars.Workspace
+func (t *OutputTemplate) Execute(vars OutputVars) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := t.tmpl.Execute(buf, vars); err != nil {
+		return "", err
+	}
+	out := filepath.Clean(buf.String())
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(vars.Workspace, out)
+	}
+	return out, nil
+}
+
+// Binary renders the output path of binary name built for platform in
+// os/arch or os/arch/variant, version and profile are the git version and
+// profile of go build. Commands locating binaries built by go build must use
+// it to get the same path.
+func (t *OutputTemplate) Binary(workspace, platform, name, version, profile string) (string, error) {
+	ps := strings.SplitN(platform, "/", 3)
+	if len(ps) < 2 || ps[0] == "" || ps[1] == "" {
+		return "", fmt.Errorf("invalid platform %q, it must be os/arch or os/arch/variant", platform)
+	}
+	vars := OutputVars{
+		Workspace: workspace,
+		OS:        ps[0],
+		Arch:      ps[1],
+		Name:      name,
+		Version:   version,
+		Profile:   profile,
+		Ext:       ExecutableExt(ps[0]),
+	}
+	if len(ps) == 3 {
+		vars.Variant = ps[2]
+	}
+	return t.Execute(vars)
+}
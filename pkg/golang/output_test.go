@@ -0,0 +1,46 @@
+package golang
+
+import "testing"
+
+func TestOutputTemplate_Execute(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		vars OutputVars
+		want string
+	}{
+		{
+			"default",
+			"{{.Workspace}}/bin/{{.OS}}_{{.Arch}}/{{.Name}}{{.Ext}}",
+			OutputVars{Workspace: "/ws", OS: "linux", Arch: "amd64", Name: "foo"},
+			"/ws/bin/linux_amd64/foo",
+		},
+		{
+			"windows",
+			"{{.Workspace}}/bin/{{.OS}}_{{.Arch}}/{{.Name}}{{.Ext}}",
+			OutputVars{Workspace: "/ws", OS: "windows", Arch: "amd64", Name: "foo", Ext: ExecutableExt("windows")},
+			"/ws/bin/windows_amd64/foo.exe",
+		},
+		{
+			"relative with version",
+			"dist/{{.Version}}/{{.Name}}-{{.OS}}-{{.Arch}}",
+			OutputVars{Workspace: "/ws", OS: "darwin", Arch: "arm64", Name: "foo", Version: "v1.0.0"},
+			"/ws/dist/v1.0.0/foo-darwin-arm64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseOutputTemplate(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tmpl.Execute(tt.vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
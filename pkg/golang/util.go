@@ -17,9 +17,8 @@ var (
 	go1160 = semver.MustParse("v1.16.0")
 )
 
-func getGoVersion() (*semver.Version, error) {
-	cmd := runner.NewRunner("go")
-	out, err := cmd.RunCombinedOutput("version")
+func getGoVersion(goCmd runner.Executor) (*semver.Version, error) {
+	out, err := goCmd.ReadOnly().RunCombinedOutput("version")
 	if err != nil {
 		return nil, err
 	}
@@ -36,7 +35,7 @@ func getGoVersion() (*semver.Version, error) {
 }
 
 func VerifyGoVersion(minimumGoVersion string) error {
-	sv1, err := getGoVersion()
+	sv1, err := getGoVersion(runner.NewRunner("go"))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,295 @@
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+// Phase is the lifecycle point a hook runs at, it is also the hook file name
+type Phase string
+
+const (
+	PreBuild  Phase = "pre-build"
+	PostBuild Phase = "post-build"
+)
+
+// Pre returns the phase before command, e.g. pre-format
+func Pre(command string) Phase {
+	return Phase("pre-" + command)
+}
+
+// Post returns the phase after command, e.g. post-format
+func Post(command string) Phase {
+	return Phase("post-" + command)
+}
+
+// Find returns hook files of phase in dir in order. The first one is the
+// file named {phase}, then {phase}.{suffix} for every suffix, e.g.
+// pre-build, pre-build.linux, pre-build.linux_amd64
+func Find(dir string, phase Phase, suffixes ...string) []string {
+	if dir == "" {
+		return nil
+	}
+	names := []string{string(phase)}
+	for _, s := range suffixes {
+		names = append(names, string(phase)+"."+s)
+	}
+	files := []string{}
+	for _, name := range names {
+		file := filepath.Join(dir, name)
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// Runner runs hook files
+type Runner struct {
+	// Timeout is the maximum duration of one hook, 0 means no timeout
+	Timeout time.Duration
+	// Env is the environment variables passed to hooks
+	Env map[string]string
+	// Dir is the working dir of hook steps, relative dir of a step is
+	// related to it
+	Dir string
+	// NewExecutor returns the executor of command name run by hooks, it
+	// defaults to runner.NewRunner. Tests set it to return runner.Fake.
+	NewExecutor func(name string) runner.Executor
+}
+
+func (r *Runner) executor(name string) runner.Executor {
+	if r.NewExecutor != nil {
+		return r.NewExecutor(name)
+	}
+	return runner.NewRunner(name)
+}
+
+// WithEnv returns a copy of runner with extra env
+func (r *Runner) WithEnv(env map[string]string) *Runner {
+	merged := map[string]string{}
+	for k, v := range r.Env {
+		merged[k] = v
+	}
+	for k, v := range env {
+		merged[k] = v
+	}
+	return &Runner{Timeout: r.Timeout, Env: merged, Dir: r.Dir, NewExecutor: r.NewExecutor}
+}
+
+// Run runs hook files of phase found in dir in order, see Find
+func (r *Runner) Run(ctx context.Context, logger log.Logger, dir string, phase Phase, suffixes ...string) error {
+	logger.V(2).Info("detecting hook", "dir", dir, "phase", phase)
+	for _, file := range Find(dir, phase, suffixes...) {
+		if err := r.RunFile(ctx, logger, phase, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunFile runs a hook file with env MAKE_RULES_PHASE={phase}. Its output
+// is streamed to logger line by line.
+//
+// An executable file with shebang or in binary is run directly. Otherwise
+// the interpreter is read from shebang, and bash is used if the file has no
+// shebang, e.g. an executable script without shebang.
+func (r *Runner) RunFile(ctx context.Context, logger log.Logger, phase Phase, file string) error {
+	name, args, err := command(file)
+	if err != nil {
+		return err
+	}
+	return r.run(ctx, logger.WithValues("path", file), phase, r.executor(name), args, nil)
+}
+
+// Condition is the build state which hook steps are matched against
+type Condition struct {
+	// Platforms are the platforms of build, e.g. linux/amd64
+	Platforms []string
+	// CleanTree is true if the git tree is clean
+	CleanTree bool
+}
+
+// Match returns true if all conditions in when are met, a nil when always
+// matches
+func (c Condition) Match(when *config.HookWhen) bool {
+	if when == nil {
+		return true
+	}
+	if when.CleanTree && !c.CleanTree {
+		return false
+	}
+	if len(when.Platforms) == 0 {
+		return true
+	}
+	for _, p := range c.Platforms {
+		for _, glob := range when.Platforms {
+			if ok, _ := path.Match(glob, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RunSteps runs hook steps matching cond in order. A failed step with
+// ContinueOnError is logged and the next step runs.
+func (r *Runner) RunSteps(ctx context.Context, logger log.Logger, phase Phase, steps []config.HookStep, cond Condition) error {
+	for _, step := range steps {
+		stepLogger := logger.WithValues("step", StepName(step))
+		if !cond.Match(step.When) {
+			stepLogger.V(2).Info("hook step skipped", "phase", phase)
+			continue
+		}
+		err := r.RunStep(ctx, stepLogger, phase, step)
+		if err != nil && step.ContinueOnError {
+			stepLogger.Info("hook step failed, continue on error", "phase", phase, "error", err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StepName returns name of step, it defaults to the command
+func StepName(step config.HookStep) string {
+	if step.Name != "" {
+		return step.Name
+	}
+	return step.Command
+}
+
+// RunStep runs a hook step with the same env as hook files. ${VAR} in args
+// and env values is expanded with hook env and the base env of commands.
+func (r *Runner) RunStep(ctx context.Context, logger log.Logger, phase Phase, step config.HookStep) error {
+	expand := func(s string) string {
+		return os.Expand(s, func(key string) string {
+			if v, ok := step.Env[key]; ok {
+				return v
+			}
+			if v, ok := r.Env[key]; ok {
+				return v
+			}
+			// env resolved from make-rules.yaml, dotenv files and --env
+			return runner.BaseEnv()[key]
+		})
+	}
+	args := make([]string, 0, len(step.Args))
+	for _, a := range step.Args {
+		args = append(args, expand(a))
+	}
+	env := map[string]string{}
+	for k, v := range step.Env {
+		env[k] = expand(v)
+	}
+	dir := step.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(r.Dir, dir)
+	}
+	cmd := r.executor(step.Command).WithDir(dir)
+	return r.run(ctx, logger, phase, cmd, args, env)
+}
+
+// run runs cmd with hook env and extra env, and streams its output to logger
+func (r *Runner) run(ctx context.Context, logger log.Logger, phase Phase, cmd runner.Executor, args []string, env map[string]string) error {
+	kvs := []string{"MAKE_RULES_PHASE", string(phase)}
+	for _, m := range []map[string]string{r.Env, env} {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			kvs = append(kvs, k, m[k])
+		}
+	}
+	cmd = cmd.WithEnvs(kvs...)
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	logger.Info("hook started", "phase", phase)
+	w := runner.NewLogWriter(logger.WithValues("phase", phase), "hook output")
+	err := cmd.RunStreamContext(ctx, w, args...)
+	w.Close()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("hook timed out after %v: %w", r.Timeout, err)
+		}
+		logger.Error(err, "hook failed", "phase", phase)
+		return err
+	}
+	logger.Info("hook completed", "phase", phase)
+	return nil
+}
+
+// command returns the command name and args to run hook file
+func command(file string) (string, []string, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", nil, err
+	}
+	interpreter, binary, err := readShebang(file)
+	if err != nil {
+		return "", nil, err
+	}
+	// a script without shebang can not be exec'd, the kernel returns ENOEXEC
+	if info.Mode().Perm()&0111 != 0 && (len(interpreter) > 0 || binary) {
+		return file, nil, nil
+	}
+	if len(interpreter) == 0 {
+		return "bash", []string{file}, nil
+	}
+	args := append(interpreter[1:], file)
+	return interpreter[0], args, nil
+}
+
+// readShebang returns the interpreter and its args in the first line of
+// file, e.g. ["/usr/bin/env", "python3"] for "#!/usr/bin/env python3".
+// binary is true if file is not a text file, e.g. a compiled program.
+func readShebang(file string) (interpreter []string, binary bool, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	// text files have no NUL bytes
+	head, _ := r.Peek(512)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, true, nil
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && line == "" {
+		// empty file
+		return nil, false, nil
+	}
+	if !strings.HasPrefix(line, "#!") {
+		return nil, false, nil
+	}
+	return strings.Fields(strings.TrimPrefix(line, "#!")), false, nil
+}
@@ -0,0 +1,111 @@
+package hook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/zoumo/golib/log"
+
+	"github.com/zoumo/make-rules/pkg/config"
+	"github.com/zoumo/make-rules/pkg/runner"
+)
+
+func TestCondition_Match(t *testing.T) {
+	tests := []struct {
+		name string
+		cond Condition
+		when *config.HookWhen
+		want bool
+	}{
+		{"nil when", Condition{}, nil, true},
+		{"clean tree required", Condition{CleanTree: false}, &config.HookWhen{CleanTree: true}, false},
+		{"clean tree", Condition{CleanTree: true}, &config.HookWhen{CleanTree: true}, true},
+		{"platform glob", Condition{Platforms: []string{"linux/amd64"}}, &config.HookWhen{Platforms: []string{"linux/*"}}, true},
+		{"any platform", Condition{Platforms: []string{"darwin/arm64", "linux/arm64"}}, &config.HookWhen{Platforms: []string{"linux/arm64"}}, true},
+		{"platform mismatch", Condition{Platforms: []string{"windows/amd64"}}, &config.HookWhen{Platforms: []string{"linux/*"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cond.Match(tt.when); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"pre-build", "pre-build.linux_amd64", "pre-build.darwin"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := Find(dir, PreBuild, "linux", "linux_amd64")
+	want := []string{filepath.Join(dir, "pre-build"), filepath.Join(dir, "pre-build.linux_amd64")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() = %v, want %v", got, want)
+	}
+}
+
+func TestRunner_RunFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook scripts need bash")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	hooks := map[string]string{
+		// executable script without shebang is run by bash
+		"no-shebang": "echo -n $MAKE_RULES_PHASE > " + out,
+		"shebang":    "#!/bin/sh\necho -n $MAKE_RULES_PHASE > " + out,
+	}
+	for name, content := range hooks {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(out)
+		if err := (&Runner{}).RunFile(context.Background(), log.Log, PreBuild, file); err != nil {
+			t.Errorf("RunFile() of %s = %v", name, err)
+			continue
+		}
+		if data, _ := os.ReadFile(out); string(data) != string(PreBuild) {
+			t.Errorf("RunFile() of %s wrote %q, want %q", name, data, PreBuild)
+		}
+	}
+}
+
+func TestRunner_RunSteps(t *testing.T) {
+	// env resolved by make-rules, it is not in os env
+	runner.SetEnv(map[string]string{"REGISTRY": "ghcr.io/a"})
+	defer runner.SetEnv(nil)
+
+	fakes := map[string]*runner.Fake{"cosign": runner.NewFake("cosign"), "lint": runner.NewFake("lint")}
+	fakes["cosign"].On("sign", "*")
+	fakes["lint"].On("...").Return("", "failed", 1)
+	r := &Runner{
+		Env: map[string]string{"MAKE_RULES_COMMAND": "build"},
+		Dir: "/ws",
+		NewExecutor: func(name string) runner.Executor {
+			return fakes[name]
+		},
+	}
+	steps := []config.HookStep{
+		{Command: "lint", ContinueOnError: true},
+		{Command: "cosign", Args: []string{"sign", "${REGISTRY}/${MAKE_RULES_COMMAND}"}, Dir: "dist"},
+	}
+	if err := r.RunSteps(context.Background(), log.Log, PostBuild, steps, Condition{}); err != nil {
+		t.Fatal(err)
+	}
+	calls := fakes["cosign"].Calls()
+	if len(calls) != 1 {
+		t.Fatalf("cosign calls = %v, want 1 call", calls)
+	}
+	c := calls[0]
+	if c.String() != "cosign sign ghcr.io/a/build" || c.Dir != "/ws/dist" || c.Env["MAKE_RULES_PHASE"] != string(PostBuild) {
+		t.Errorf("cosign call = %s in %s with env %v", c, c.Dir, c.Env)
+	}
+}
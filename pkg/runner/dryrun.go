@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/zoumo/golib/log"
+)
+
+// dryRun is set by the global --dry-run flag
+var dryRun bool
+
+// DryRun returns true if commands are logged instead of running. Commands
+// should not write files in dry run mode either.
+func DryRun() bool {
+	return dryRun
+}
+
+// SetDryRun enables or disables dry run mode
+func SetDryRun(enabled bool) {
+	dryRun = enabled
+}
+
+// skipDryRun logs the command and returns true if it must not run in dry
+// run mode, commands marked by ReadOnly always run
+func (c *Runner) skipDryRun(args []string) bool {
+	if !dryRun || c.readOnly {
+		return false
+	}
+	cmd := c.cmd(args...)
+	log.Log.WithName("dry-run").Info("Dry run, command is not executed",
+		"cmd", cmd.String(), "dir", cmd.Dir, "env", envDiff(c.environ(), os.Environ()))
+	return true
+}
+
+// envDiff returns env changed from the process env environ, in KEY=VALUE
+// for set keys and -KEY for unset keys
+func envDiff(env map[string]string, environ []string) []string {
+	base := map[string]string{}
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			base[k] = v
+		}
+	}
+	diff := []string{}
+	for k, v := range env {
+		if old, ok := base[k]; !ok || old != v {
+			diff = append(diff, k+"="+v)
+		}
+	}
+	for k := range base {
+		if _, ok := env[k]; !ok {
+			diff = append(diff, "-"+k)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
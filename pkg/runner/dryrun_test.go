@@ -0,0 +1,32 @@
+//go:build !windows
+
+package runner
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRunner_DryRun(t *testing.T) {
+	SetDryRun(true)
+	defer SetDryRun(false)
+
+	cmd := NewRunner("sh")
+	if _, err := cmd.Run(context.Background(), "-c", "exit 1"); err != nil {
+		t.Errorf("Run() in dry run = %v, want nil", err)
+	}
+	out, err := cmd.ReadOnly().RunOutput("-c", "echo foo")
+	if err != nil || string(out) != "foo\n" {
+		t.Errorf("RunOutput() of read-only command = %q, %v, want foo", out, err)
+	}
+}
+
+func TestEnvDiff(t *testing.T) {
+	env := map[string]string{"GOOS": "linux", "HOME": "/root", "PATH": "/bin"}
+	got := envDiff(env, []string{"HOME=/root", "PATH=/usr/bin", "TERM=xterm"})
+	want := []string{"-TERM", "GOOS=linux", "PATH=/bin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("envDiff() = %v, want %v", got, want)
+	}
+}
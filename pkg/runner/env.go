@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"os"
+	"strings"
+)
+
+// DefaultEnv are env of all commands if they are not set by any source, e.g.
+// process env
+var DefaultEnv = map[string]string{
+	"GO111MODULE": "on",
+}
+
+// baseEnv is the env of all commands set by SetEnv
+var baseEnv map[string]string
+
+// SetEnv sets the base env of all commands, e.g. the process env merged with
+// env configured in make-rules.yaml. Runners created before still use it,
+// since env is resolved when a command runs.
+func SetEnv(env map[string]string) {
+	baseEnv = env
+}
+
+// BaseEnv returns the base env of all commands, it is DefaultEnv overridden
+// by the process env if SetEnv is not called
+func BaseEnv() map[string]string {
+	if baseEnv != nil {
+		return baseEnv
+	}
+	env := map[string]string{}
+	for k, v := range DefaultEnv {
+		env[k] = v
+	}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// mergeEnv returns the base env with env set by WithEnvs of executors
+func mergeEnv(env map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range BaseEnv() {
+		merged[k] = v
+	}
+	for k, v := range env {
+		merged[k] = v
+	}
+	return merged
+}
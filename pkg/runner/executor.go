@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/zoumo/golib/log"
+)
+
+// Executor runs a command with args. Runner executes real processes, Fake
+// replies with scripted outputs for tests. Commands hold an Executor instead
+// of *Runner, so their logic can be tested without real tools.
+//
+// With* methods return a copy of the executor, the receiver is not changed.
+type Executor interface {
+	// WithDir returns an executor running commands in dir
+	WithDir(dir string) Executor
+	// WithEnvs returns an executor with extra env in key, value pairs
+	WithEnvs(kvs ...string) Executor
+	// WithOutput returns an executor streaming stdout and stderr of Run to
+	// the writers line by line
+	WithOutput(stdout, stderr io.Writer) Executor
+	// WithLogger returns an executor logging every output line of Run
+	WithLogger(logger log.Logger, msg string) Executor
+	// WithTimeout returns an executor whose commands run by Run are
+	// terminated if they do not complete in d
+	WithTimeout(d time.Duration) Executor
+	// ReadOnly returns an executor whose commands run even in dry run mode
+	ReadOnly() Executor
+
+	// FilterEnv returns env of requires keys which are set
+	FilterEnv(requires []string) map[string]string
+
+	// RunOutput runs the command and returns its stdout
+	RunOutput(args ...string) ([]byte, error)
+	// RunCombinedOutput runs the command and returns its combined output
+	RunCombinedOutput(args ...string) ([]byte, error)
+	// RunCombinedOutputContext is like RunCombinedOutput, but the command
+	// is terminated if ctx is done
+	RunCombinedOutputContext(ctx context.Context, args ...string) ([]byte, error)
+	// RunStreamContext runs the command and writes its combined output to w
+	RunStreamContext(ctx context.Context, w io.Writer, args ...string) error
+	// Run runs the command and returns its combined output, the output is
+	// also returned if the command fails
+	Run(ctx context.Context, args ...string) ([]byte, error)
+}
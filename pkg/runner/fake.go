@@ -0,0 +1,272 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zoumo/golib/log"
+)
+
+var _ Executor = &Fake{}
+
+// Fake is an Executor for tests. It runs no process, but records every
+// command and replies with the first rule matching its args. Executors
+// returned by With* share rules and calls with their parent.
+//
+//	goCmd := runner.NewFake("go")
+//	goCmd.On("list", "-m").Return("example.com/foo\n", "", 0)
+//	goCmd.On("build", "...").Do(func(c runner.Call) error { ... })
+type Fake struct {
+	name     string
+	env      map[string]string
+	dir      string
+	stdout   io.Writer
+	stderr   io.Writer
+	timeout  time.Duration
+	readOnly bool
+
+	state *fakeState
+}
+
+type fakeState struct {
+	mu    sync.Mutex
+	rules []*FakeRule
+	calls []Call
+}
+
+// Call is a command run by Fake
+type Call struct {
+	Name string
+	Args []string
+	Dir  string
+	// Env is the env set by WithEnvs
+	Env      map[string]string
+	Timeout  time.Duration
+	ReadOnly bool
+}
+
+// String returns the command line of call
+func (c Call) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// FakeRule is the scripted reply of Fake to commands matching its args
+type FakeRule struct {
+	args     []string
+	stdout   string
+	stderr   string
+	exitCode int
+	do       func(Call) error
+	once     bool
+	used     bool
+}
+
+// NewFake returns a Fake executor of command name without any rule
+func NewFake(name string) *Fake {
+	return &Fake{
+		name:  name,
+		env:   map[string]string{},
+		state: &fakeState{},
+	}
+}
+
+// On adds a rule matching commands whose args are args. "*" matches any one
+// arg, and a trailing "..." matches all remaining args. Rules are matched in
+// the order they are added. A command matching no rule fails.
+func (f *Fake) On(args ...string) *FakeRule {
+	r := &FakeRule{args: args}
+	f.state.mu.Lock()
+	defer f.state.mu.Unlock()
+	f.state.rules = append(f.state.rules, r)
+	return r
+}
+
+// Return sets the stdout, stderr and exit code of the rule, a non-zero exit
+// code fails the command
+func (r *FakeRule) Return(stdout, stderr string, exitCode int) *FakeRule {
+	r.stdout = stdout
+	r.stderr = stderr
+	r.exitCode = exitCode
+	return r
+}
+
+// Do sets fn called with the command when the rule matches, e.g. to write
+// the output file of go build. The command fails if fn returns an error.
+func (r *FakeRule) Do(fn func(Call) error) *FakeRule {
+	r.do = fn
+	return r
+}
+
+// Once makes the rule match only one command, so that rules added later
+// reply to the following commands
+func (r *FakeRule) Once() *FakeRule {
+	r.once = true
+	return r
+}
+
+func (r *FakeRule) match(args []string) bool {
+	if r.once && r.used {
+		return false
+	}
+	for i, want := range r.args {
+		if want == "..." && i == len(r.args)-1 {
+			return true
+		}
+		if i >= len(args) || (want != "*" && want != args[i]) {
+			return false
+		}
+	}
+	return len(args) == len(r.args)
+}
+
+// Calls returns all commands run by the fake and its copies in order
+func (f *Fake) Calls() []Call {
+	f.state.mu.Lock()
+	defer f.state.mu.Unlock()
+	return append([]Call{}, f.state.calls...)
+}
+
+func (f *Fake) clone() *Fake {
+	ff := *f
+	ff.env = map[string]string{}
+	for k, v := range f.env {
+		ff.env[k] = v
+	}
+	return &ff
+}
+
+func (f *Fake) WithDir(dir string) Executor {
+	ff := f.clone()
+	ff.dir = dir
+	return ff
+}
+
+func (f *Fake) WithEnvs(kvs ...string) Executor {
+	ff := f.clone()
+	for i := 0; i+1 < len(kvs); i += 2 {
+		ff.env[kvs[i]] = kvs[i+1]
+	}
+	return ff
+}
+
+func (f *Fake) WithOutput(stdout, stderr io.Writer) Executor {
+	ff := f.clone()
+	ff.stdout = stdout
+	ff.stderr = stderr
+	return ff
+}
+
+func (f *Fake) WithLogger(logger log.Logger, msg string) Executor {
+	return f.WithOutput(
+		NewLogWriter(logger.WithValues("stream", "stdout"), msg),
+		NewLogWriter(logger.WithValues("stream", "stderr"), msg),
+	)
+}
+
+func (f *Fake) WithTimeout(d time.Duration) Executor {
+	ff := f.clone()
+	ff.timeout = d
+	return ff
+}
+
+func (f *Fake) ReadOnly() Executor {
+	ff := f.clone()
+	ff.readOnly = true
+	return ff
+}
+
+// FilterEnv returns required env of the base env and env set by WithEnvs,
+// the same as Runner
+func (f *Fake) FilterEnv(requires []string) map[string]string {
+	env := mergeEnv(f.env)
+	required := map[string]string{}
+	for _, k := range requires {
+		if v, ok := env[k]; ok {
+			required[k] = v
+		}
+	}
+	return required
+}
+
+func (f *Fake) RunOutput(args ...string) ([]byte, error) {
+	stdout, _, err := f.exec(context.Background(), args)
+	if err != nil {
+		return nil, err
+	}
+	return stdout, nil
+}
+
+func (f *Fake) RunCombinedOutput(args ...string) ([]byte, error) {
+	return f.RunCombinedOutputContext(context.Background(), args...)
+}
+
+func (f *Fake) RunCombinedOutputContext(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := f.Run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (f *Fake) RunStreamContext(ctx context.Context, w io.Writer, args ...string) error {
+	_, err := f.WithOutput(w, w).Run(ctx, args...)
+	return err
+}
+
+func (f *Fake) Run(ctx context.Context, args ...string) ([]byte, error) {
+	stdout, stderr, err := f.exec(ctx, args)
+	for _, o := range []struct {
+		w    io.Writer
+		data []byte
+	}{{f.stdout, stdout}, {f.stderr, stderr}} {
+		lw := newLineWriter(o.w)
+		lw.Write(o.data) //nolint:errcheck
+		lw.Close()
+	}
+	return append(stdout, stderr...), err
+}
+
+// exec records the command and replies with the first matching rule
+func (f *Fake) exec(ctx context.Context, args []string) ([]byte, []byte, error) {
+	call := Call{
+		Name:     f.name,
+		Args:     append([]string{}, args...),
+		Dir:      f.dir,
+		Env:      f.clone().env,
+		Timeout:  f.timeout,
+		ReadOnly: f.readOnly,
+	}
+	f.state.mu.Lock()
+	f.state.calls = append(f.state.calls, call)
+	var rule *FakeRule
+	for _, r := range f.state.rules {
+		if r.match(args) {
+			r.used = true
+			rule = r
+			break
+		}
+	}
+	f.state.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, NewRunnerError(call.String(), "", err)
+	}
+	if rule == nil {
+		return nil, nil, NewRunnerError(call.String(), "", errors.New("no fake rule matches the command"))
+	}
+	stdout, stderr := []byte(rule.stdout), []byte(rule.stderr)
+	if rule.do != nil {
+		if err := rule.do(call); err != nil {
+			return stdout, stderr, NewRunnerError(call.String(), rule.stdout+rule.stderr, err)
+		}
+	}
+	if rule.exitCode != 0 {
+		return stdout, stderr, NewRunnerError(call.String(), rule.stdout+rule.stderr, fmt.Errorf("exit status %d", rule.exitCode))
+	}
+	return stdout, stderr, nil
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFake(t *testing.T) {
+	f := NewFake("go")
+	f.On("env", "GOVERSION").Return("go1.99.0\n", "", 0).Once()
+	f.On("env", "*").Return("", "unknown env", 2)
+	f.On("build", "...").Return("", "compiled\n", 0)
+
+	out, err := f.RunOutput("env", "GOVERSION")
+	if err != nil || string(out) != "go1.99.0\n" {
+		t.Errorf("RunOutput() = %q, %v, want go1.99.0", out, err)
+	}
+	// the once rule is used up
+	if _, err := f.RunOutput("env", "GOVERSION"); err == nil {
+		t.Errorf("RunOutput() should fail with exit code 2")
+	}
+	if _, err := f.RunOutput("list"); err == nil {
+		t.Errorf("RunOutput() should fail if no rule matches")
+	}
+
+	stderr := &bytes.Buffer{}
+	cmd := f.WithDir("/src").WithEnvs("GOOS", "linux").WithOutput(nil, stderr)
+	if _, err := cmd.Run(context.Background(), "build", "-o", "foo", "./cmd/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.String() != "compiled\n" {
+		t.Errorf("stderr = %q, want compiled", stderr.String())
+	}
+
+	calls := f.Calls()
+	if len(calls) != 4 {
+		t.Fatalf("Calls() returns %d calls, want 4", len(calls))
+	}
+	last := calls[3]
+	if last.String() != "go build -o foo ./cmd/foo" || last.Dir != "/src" || last.Env["GOOS"] != "linux" {
+		t.Errorf("last call = %+v", last)
+	}
+}
+
+func TestFake_FilterEnv(t *testing.T) {
+	SetEnv(map[string]string{"GOPATH": "/go", "GOFLAGS": "-mod=mod"})
+	defer SetEnv(nil)
+
+	keys := []string{"GOPATH", "GOFLAGS", "GOOS"}
+	fake := NewFake("go").WithEnvs("GOOS", "linux").FilterEnv(keys)
+	real := NewRunner("go").WithEnvs("GOOS", "linux").FilterEnv(keys)
+	if !reflect.DeepEqual(fake, real) || len(fake) != 3 {
+		t.Errorf("FilterEnv() of fake = %v, want %v of runner", fake, real)
+	}
+}
@@ -0,0 +1,11 @@
+package runner
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// InitFlags registers the global flags of runner into fs
+func InitFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&dryRun, "dry-run", dryRun, "log every external command instead of running it, read-only commands still run")
+	fs.StringVar(&traceFile, "trace", traceFile, "write every external command with its timing to the file in Chrome trace-event JSON")
+}
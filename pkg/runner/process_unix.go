@@ -0,0 +1,30 @@
+//go:build !windows
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var (
+	forwardSignals  = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	terminateSignal = syscall.SIGTERM
+)
+
+// setProcessGroup runs cmd in a new process group, so that signals reach all
+// its children
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func signalGroup(cmd *exec.Cmd, sig os.Signal) {
+	if s, ok := sig.(syscall.Signal); ok && cmd.Process != nil {
+		_ = syscall.Kill(-cmd.Process.Pid, s)
+	}
+}
+
+func killGroup(cmd *exec.Cmd) {
+	signalGroup(cmd, syscall.SIGKILL)
+}
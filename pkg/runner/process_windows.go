@@ -0,0 +1,27 @@
+//go:build windows
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+)
+
+var (
+	forwardSignals  = []os.Signal{os.Interrupt}
+	terminateSignal = os.Kill
+)
+
+// setProcessGroup does nothing on windows, the console delivers Ctrl-C to
+// all processes attached to it
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func signalGroup(cmd *exec.Cmd, sig os.Signal) {
+	if sig == os.Kill && cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+func killGroup(cmd *exec.Cmd) {
+	signalGroup(cmd, os.Kill)
+}
@@ -1,12 +1,24 @@
 package runner
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"strings"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/zoumo/golib/log"
 )
 
+// killGrace is the time a command has to exit after it is signaled, the
+// process group is killed after it
+var killGrace = 10 * time.Second
+
 type Status struct {
 	cmd    string
 	output string
@@ -25,10 +37,24 @@ func (e *Status) Error() string {
 	return fmt.Sprintf("failed to run cmd: cmd=%s, err=%v, output=%s", e.cmd, e.err, e.output)
 }
 
+func (e *Status) Unwrap() error {
+	return e.err
+}
+
+var _ Executor = &Runner{}
+
+// Runner is the Executor running commands as processes
 type Runner struct {
 	name string
 	env  map[string]string
 	dir  string
+
+	// stdout and stderr receive output of Run line by line
+	stdout  io.Writer
+	stderr  io.Writer
+	timeout time.Duration
+	// readOnly commands run in dry run mode
+	readOnly bool
 }
 
 func NewRunner(name string) *Runner {
@@ -40,26 +66,26 @@ func NewRunner(name string) *Runner {
 }
 
 func (c *Runner) init() {
-	envs := os.Environ()
 	if c.env == nil {
 		c.env = make(map[string]string)
 	}
-	for _, env := range envs {
-		kv := strings.SplitN(env, "=", 2)
-		if len(kv) != 2 {
-			continue
-		}
-		c.env[kv[0]] = kv[1]
-	}
-	// overwrite env
-	c.env["GO111MODULE"] = "on"
+}
+
+// environ returns env of commands, it is the base env set by SetEnv with env
+// set by WithEnvs
+func (c *Runner) environ() map[string]string {
+	return mergeEnv(c.env)
 }
 
 func (c *Runner) clone() *Runner {
 	cc := &Runner{
-		name: c.name,
-		env:  map[string]string{},
-		dir:  c.dir,
+		name:     c.name,
+		env:      map[string]string{},
+		dir:      c.dir,
+		stdout:   c.stdout,
+		stderr:   c.stderr,
+		timeout:  c.timeout,
+		readOnly: c.readOnly,
 	}
 	for k, v := range c.env {
 		cc.env[k] = v
@@ -69,22 +95,23 @@ func (c *Runner) clone() *Runner {
 }
 
 func (c *Runner) FilterEnv(requires []string) map[string]string {
+	env := c.environ()
 	required := map[string]string{}
 	for _, k := range requires {
-		if v, ok := c.env[k]; ok {
+		if v, ok := env[k]; ok {
 			required[k] = v
 		}
 	}
 	return required
 }
 
-func (c *Runner) WithDir(dir string) *Runner {
+func (c *Runner) WithDir(dir string) Executor {
 	cc := c.clone()
 	cc.dir = dir
 	return cc
 }
 
-func (c *Runner) WithEnvs(kvs ...string) *Runner {
+func (c *Runner) WithEnvs(kvs ...string) Executor {
 	cc := c.clone()
 	length := len(kvs)
 	for i := 0; i < length; {
@@ -100,38 +127,207 @@ func (c *Runner) WithEnvs(kvs ...string) *Runner {
 	return cc
 }
 
+// WithOutput returns a runner streaming stdout and stderr of commands run by
+// Run to the writers line by line, nil writer discards the output
+func (c *Runner) WithOutput(stdout, stderr io.Writer) Executor {
+	cc := c.clone()
+	cc.stdout = stdout
+	cc.stderr = stderr
+	return cc
+}
+
+// WithLogger returns a runner logging every output line of commands run by
+// Run with logger.Info(msg, "stream", "stdout"|"stderr", "output", line)
+func (c *Runner) WithLogger(logger log.Logger, msg string) Executor {
+	return c.WithOutput(
+		NewLogWriter(logger.WithValues("stream", "stdout"), msg),
+		NewLogWriter(logger.WithValues("stream", "stderr"), msg),
+	)
+}
+
+// WithTimeout returns a runner whose commands run by Run are terminated if
+// they do not complete in d, 0 means no timeout
+func (c *Runner) WithTimeout(d time.Duration) Executor {
+	cc := c.clone()
+	cc.timeout = d
+	return cc
+}
+
+// ReadOnly returns a runner whose commands run even in dry run mode, it
+// marks commands which only read state and whose output is needed to
+// continue, e.g. go list -m
+func (c *Runner) ReadOnly() Executor {
+	cc := c.clone()
+	cc.readOnly = true
+	return cc
+}
+
 func (c *Runner) cmd(args ...string) *exec.Cmd {
-	cmd := exec.Command(c.name, args...)
+	return c.cmdContext(context.Background(), args...)
+}
+
+func (c *Runner) cmdContext(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.name, args...)
 	// merge env
-	cmd.Env = joinMap(c.env, "=")
+	cmd.Env = joinMap(c.environ(), "=")
 	cmd.Dir = c.dir
 	return cmd
 }
 
 func (c *Runner) RunOutput(args ...string) ([]byte, error) {
+	if c.skipDryRun(args) {
+		return nil, nil
+	}
 	cmd := c.cmd(args...)
+	span := c.startTrace(args)
 	out, err := cmd.Output()
 	if err != nil {
 		output := out
 		if eerr, ok := err.(*exec.ExitError); ok {
 			output = eerr.Stderr
 		}
+		span.end(exitCode(cmd), len(out)+len(output), err)
 		return nil, NewRunnerError(cmd.String(), string(output), err)
 	}
+	span.end(exitCode(cmd), len(out), nil)
 	return out, err
 }
 
 func (c *Runner) RunCombinedOutput(args ...string) ([]byte, error) {
-	cmd := c.cmd(args...)
-	out, err := cmd.CombinedOutput()
+	return c.RunCombinedOutputContext(context.Background(), args...)
+}
+
+// RunCombinedOutputContext is like RunCombinedOutput but the process group
+// is terminated if the context is done before the command completes.
+func (c *Runner) RunCombinedOutputContext(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := c.Run(ctx, args...)
 	if err != nil {
-		output := out
-		if eerr, ok := err.(*exec.ExitError); ok {
-			output = append(output, eerr.Stderr...)
+		return nil, err
+	}
+	return out, nil
+}
+
+// RunStreamContext runs the command and writes its combined output to w
+// while it is running. The output is also captured and carried by the
+// returned error if the command fails.
+func (c *Runner) RunStreamContext(ctx context.Context, w io.Writer, args ...string) error {
+	_, err := c.WithOutput(w, w).Run(ctx, args...)
+	return err
+}
+
+// Run runs the command in a new process group and returns its combined
+// output. Stdout and stderr are streamed line by line to the writers set by
+// WithOutput or WithLogger while the command is running, and the output is
+// carried by the returned error if the command fails.
+//
+// If ctx is done or the timeout set by WithTimeout expires, SIGTERM is sent
+// to the process group. SIGINT and SIGTERM received by make-rules are
+// forwarded to the process group. The group is killed if it does not exit
+// in 10 seconds after it is signaled.
+//
+// In dry run mode the command is logged instead, unless it is ReadOnly.
+func (c *Runner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	if c.skipDryRun(args) {
+		return nil, nil
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	cmd := c.cmdContext(context.Background(), args...)
+	buf := &syncBuffer{}
+	stdout, stderr := newLineWriter(c.stdout), newLineWriter(c.stderr)
+	cmd.Stdout = io.MultiWriter(buf, stdout)
+	cmd.Stderr = io.MultiWriter(buf, stderr)
+
+	span := c.startTrace(args)
+	err := runGroup(ctx, cmd)
+	stdout.Close()
+	stderr.Close()
+	span.end(exitCode(cmd), len(buf.Bytes()), err)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && c.timeout > 0 {
+			err = fmt.Errorf("timed out after %v: %w", c.timeout, ctx.Err())
+		} else if ctx.Err() != nil {
+			err = ctx.Err()
 		}
-		return nil, NewRunnerError(cmd.String(), string(output), err)
+		return buf.Bytes(), NewRunnerError(cmd.String(), buf.String(), err)
 	}
-	return out, err
+	return buf.Bytes(), nil
+}
+
+// exitCode returns the exit code of cmd, it is -1 if cmd is not started or
+// is terminated by a signal
+func exitCode(cmd *exec.Cmd) int {
+	if cmd.ProcessState == nil {
+		return -1
+	}
+	return cmd.ProcessState.ExitCode()
+}
+
+// runGroup starts cmd in a new process group and waits for it, signals are
+// forwarded to the group until it exits
+func runGroup(ctx context.Context, cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardSignals...)
+	defer signal.Stop(sigs)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	ctxDone := ctx.Done()
+	var kill <-chan time.Time
+	for {
+		select {
+		case err := <-done:
+			return err
+		case sig := <-sigs:
+			signalGroup(cmd, sig)
+			if kill == nil {
+				kill = time.After(killGrace)
+			}
+		case <-ctxDone:
+			ctxDone = nil
+			signalGroup(cmd, terminateSignal)
+			if kill == nil {
+				kill = time.After(killGrace)
+			}
+		case <-kill:
+			killGroup(cmd)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes of stdout and
+// stderr
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
 }
 
 func joinMap(m map[string]string, delimiter string) []string {
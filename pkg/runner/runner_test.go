@@ -0,0 +1,85 @@
+//go:build !windows
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunner_RunStreamsLines(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	out, err := NewRunner("sh").WithOutput(stdout, stderr).Run(context.Background(), "-c", "echo foo; echo bar >&2; printf baz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); got != "foo\nbaz" {
+		t.Errorf("stdout = %q, want %q", got, "foo\nbaz")
+	}
+	if got := stderr.String(); got != "bar\n" {
+		t.Errorf("stderr = %q, want %q", got, "bar\n")
+	}
+	if len(out) != len("foo\nbar\nbaz") {
+		t.Errorf("output = %q, want all lines", out)
+	}
+}
+
+func TestRunner_RunError(t *testing.T) {
+	_, err := NewRunner("sh").Run(context.Background(), "-c", "echo failed; exit 3")
+	var status *Status
+	if !errors.As(err, &status) {
+		t.Fatalf("Run() error = %v, want *Status", err)
+	}
+	if status.output != "failed\n" {
+		t.Errorf("error output = %q, want %q", status.output, "failed\n")
+	}
+}
+
+func TestRunner_RunTimeout(t *testing.T) {
+	old := killGrace
+	killGrace = 500 * time.Millisecond
+	defer func() { killGrace = old }()
+
+	start := time.Now()
+	// the background sleep is in the same process group, it must be killed
+	// too, otherwise Run waits for it holding the output pipe
+	_, err := NewRunner("sh").WithTimeout(100*time.Millisecond).Run(context.Background(), "-c", "trap '' TERM; sleep 10 & wait")
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("Run() error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() returns after %v, the process group is not killed", elapsed)
+	}
+}
+
+func TestRunner_Env(t *testing.T) {
+	// env set later is used by runners created before
+	cmd := NewRunner("sh").WithEnvs("BAR", "runner")
+	SetEnv(map[string]string{"FOO": "base", "BAR": "base"})
+	defer SetEnv(nil)
+
+	out, err := cmd.RunOutput("-c", "echo $FOO $BAR $HOME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out); got != "base runner\n" {
+		t.Errorf("RunOutput() = %q, want %q", got, "base runner\n")
+	}
+}
+
+func TestBaseEnv(t *testing.T) {
+	SetEnv(nil)
+	t.Setenv("GO111MODULE", "")
+	if got := BaseEnv()["GO111MODULE"]; got != "" {
+		t.Errorf("BaseEnv() GO111MODULE = %q, want process env to override defaults", got)
+	}
+	os.Unsetenv("GO111MODULE") //nolint:errcheck
+	if got := BaseEnv()["GO111MODULE"]; got != "on" {
+		t.Errorf("BaseEnv() GO111MODULE = %q, want default on", got)
+	}
+}
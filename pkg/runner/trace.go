@@ -0,0 +1,191 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// traceSummaryCommands is the number of slowest commands in trace summary
+	traceSummaryCommands = 10
+	// traceSummaryWidth is the maximum width of command in trace summary
+	traceSummaryWidth = 120
+)
+
+// traceFile is set by the global --trace flag, commands are not traced if
+// it is empty
+var traceFile string
+
+var tracer = &commandTracer{start: time.Now()}
+
+// traceEvent is a complete event in Chrome trace-event format, see
+// https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type traceEvent struct {
+	Name string `json:"name"`
+	Cat  string `json:"cat"`
+	Ph   string `json:"ph"`
+	// TS and Dur are in microseconds
+	TS   int64     `json:"ts"`
+	Dur  int64     `json:"dur"`
+	PID  int       `json:"pid"`
+	TID  int       `json:"tid"`
+	Args traceArgs `json:"args"`
+}
+
+type traceArgs struct {
+	Argv        []string `json:"argv"`
+	Dir         string   `json:"dir,omitempty"`
+	ExitCode    int      `json:"exitCode"`
+	OutputBytes int      `json:"outputBytes"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// commandTracer records commands run by Runner. Commands running at the
+// same time are put in different lanes (tid), so they do not overlap in
+// trace viewers.
+type commandTracer struct {
+	start time.Time
+
+	mu     sync.Mutex
+	events []traceEvent
+	lanes  []bool
+}
+
+// traceSpan is a running command
+type traceSpan struct {
+	start time.Time
+	lane  int
+	argv  []string
+	dir   string
+}
+
+// startTrace starts tracing the command, it returns nil if tracing is
+// disabled
+func (c *Runner) startTrace(args []string) *traceSpan {
+	if traceFile == "" {
+		return nil
+	}
+	t := tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lane := 0
+	for lane < len(t.lanes) && t.lanes[lane] {
+		lane++
+	}
+	if lane == len(t.lanes) {
+		t.lanes = append(t.lanes, false)
+	}
+	t.lanes[lane] = true
+	return &traceSpan{
+		start: time.Now(),
+		lane:  lane,
+		argv:  append([]string{c.name}, args...),
+		dir:   c.dir,
+	}
+}
+
+// end records the command, exitCode is -1 if the command did not exit
+// normally
+func (s *traceSpan) end(exitCode, outputBytes int, err error) {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	t := tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lanes[s.lane] = false
+	event := traceEvent{
+		Name: traceName(s.argv),
+		Cat:  "command",
+		Ph:   "X",
+		TS:   s.start.Sub(t.start).Microseconds(),
+		Dur:  end.Sub(s.start).Microseconds(),
+		PID:  os.Getpid(),
+		TID:  s.lane + 1,
+		Args: traceArgs{
+			Argv:        s.argv,
+			Dir:         s.dir,
+			ExitCode:    exitCode,
+			OutputBytes: outputBytes,
+		},
+	}
+	if err != nil {
+		event.Args.Error = err.Error()
+	}
+	t.events = append(t.events, event)
+}
+
+// traceName returns the base name of command with its leading sub commands,
+// e.g. "go mod edit" of "go mod edit -fmt ..."
+func traceName(argv []string) string {
+	name := []string{filepath.Base(argv[0])}
+	for _, arg := range argv[1:] {
+		if len(name) == 3 || strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "/=@. ") {
+			break
+		}
+		name = append(name, arg)
+	}
+	return strings.Join(name, " ")
+}
+
+// WriteTrace writes commands recorded by Runner to the file set by --trace
+// in Chrome trace-event JSON, which can be opened by chrome://tracing or
+// Perfetto, and prints the slowest commands to w. It does nothing if --trace
+// is not set.
+func WriteTrace(w io.Writer) error {
+	if traceFile == "" {
+		return nil
+	}
+	t := tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data, err := json.MarshalIndent(map[string]any{
+		"traceEvents":     t.events,
+		"displayTimeUnit": "ms",
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(traceFile, data, 0644); err != nil {
+		return err
+	}
+	writeTraceSummary(w, t.events)
+	return nil
+}
+
+// writeTraceSummary prints a table of the slowest commands in events
+func writeTraceSummary(w io.Writer, events []traceEvent) {
+	slowest := append([]traceEvent{}, events...)
+	sort.SliceStable(slowest, func(i, j int) bool { return slowest[i].Dur > slowest[j].Dur })
+	var total time.Duration
+	for _, e := range events {
+		total += time.Duration(e.Dur) * time.Microsecond
+	}
+	fmt.Fprintf(w, "\nTrace written to %s: %d commands, %v in total\n", traceFile, len(events), total.Round(time.Millisecond))
+	if len(slowest) == 0 {
+		return
+	}
+	if len(slowest) > traceSummaryCommands {
+		slowest = slowest[:traceSummaryCommands]
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DURATION\tEXIT\tCOMMAND")
+	for _, e := range slowest {
+		cmd := strings.Join(e.Args.Argv, " ")
+		if len(cmd) > traceSummaryWidth {
+			cmd = cmd[:traceSummaryWidth-3] + "..."
+		}
+		fmt.Fprintf(tw, "%v\t%d\t%s\n", (time.Duration(e.Dur) * time.Microsecond).Round(time.Millisecond), e.Args.ExitCode, cmd)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,77 @@
+//go:build !windows
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceName(t *testing.T) {
+	tests := map[string]string{
+		"go mod edit -fmt -require=a@v1": "go mod edit",
+		"go list -m":                     "go list",
+		"/usr/bin/docker run --rm":       "docker run",
+		"bash /src/hooks/pre-build":      "bash",
+		"go mod download -json a@v1":     "go mod download",
+	}
+	for cmd, want := range tests {
+		if got := traceName(strings.Fields(cmd)); got != want {
+			t.Errorf("traceName(%q) = %q, want %q", cmd, got, want)
+		}
+	}
+}
+
+func TestWriteTrace(t *testing.T) {
+	traceFile = filepath.Join(t.TempDir(), "trace.json")
+	tracer = &commandTracer{start: time.Now()}
+	defer func() { traceFile = "" }()
+
+	cmd := NewRunner("sh")
+	if _, err := cmd.RunOutput("-c", "echo foo"); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Run(context.Background(), "-c", "exit 3") //nolint:errcheck
+	// concurrent commands are put in different lanes
+	outer := cmd.startTrace([]string{"outer"})
+	inner := cmd.startTrace([]string{"inner"})
+	inner.end(0, 0, nil)
+	outer.end(0, 0, nil)
+
+	summary := &bytes.Buffer{}
+	if err := WriteTrace(summary); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(traceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}{}
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatal(err)
+	}
+	events := trace.TraceEvents
+	if len(events) != 4 {
+		t.Fatalf("trace has %d events, want 4", len(events))
+	}
+	if e := events[0]; e.Ph != "X" || e.Args.ExitCode != 0 || e.Args.OutputBytes != 4 {
+		t.Errorf("event of echo = %+v", e)
+	}
+	if e := events[1]; e.Args.ExitCode != 3 || e.Args.Error == "" {
+		t.Errorf("event of exit 3 = %+v", e)
+	}
+	if events[2].TID == events[3].TID {
+		t.Errorf("concurrent commands are in the same lane %d", events[2].TID)
+	}
+	if !strings.Contains(summary.String(), "4 commands") || !strings.Contains(summary.String(), "sh -c exit 3") {
+		t.Errorf("summary = %q", summary.String())
+	}
+}
@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/zoumo/golib/log"
+)
+
+// LogWriter is an io.WriteCloser which logs every line written to it
+type LogWriter struct {
+	logger log.Logger
+	msg    string
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// NewLogWriter returns a LogWriter logging every line by logger.Info(msg, "output", line)
+func NewLogWriter(logger log.Logger, msg string) *LogWriter {
+	return &LogWriter{logger: logger, msg: msg}
+}
+
+func (w *LogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// incomplete line, put it back
+			w.buf.Reset()
+			w.buf.WriteString(line)
+			break
+		}
+		w.logger.Info(w.msg, "output", line[:len(line)-1])
+	}
+	return len(p), nil
+}
+
+// Close logs the last incomplete line
+func (w *LogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.logger.Info(w.msg, "output", w.buf.String())
+		w.buf.Reset()
+	}
+	return nil
+}
+
+// lineWriter writes complete lines to w, so lines of commands writing to the
+// same w are not interleaved
+type lineWriter struct {
+	w io.Writer
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// newLineWriter returns a lineWriter of w, it discards everything if w is nil
+func newLineWriter(w io.Writer) *lineWriter {
+	if w == nil {
+		w = io.Discard
+	}
+	return &lineWriter{w: w}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	if i := bytes.LastIndexByte(w.buf.Bytes(), '\n'); i >= 0 {
+		if _, err := w.w.Write(w.buf.Next(i + 1)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close writes the last incomplete line. A LogWriter is closed to log the
+// line, other writers are left open since they may be shared, e.g. os.Stdout.
+func (w *lineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		if _, err := w.w.Write(w.buf.Next(w.buf.Len())); err != nil {
+			return err
+		}
+	}
+	if lw, ok := w.w.(*LogWriter); ok {
+		return lw.Close()
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package sbom
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// cyclonedx types are the subset of CycloneDX 1.5 JSON used by make-rules
+
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp,omitempty"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Hashes     []cdxHash     `json:"hashes,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// CycloneDX returns the CycloneDX 1.5 JSON SBOM of artifact
+func (a *Artifact) CycloneDX() ([]byte, error) {
+	main := a.mainModule()
+	mainComponent := cdxComponent{
+		Type:    "application",
+		BOMRef:  main.purl(),
+		Name:    filepath.Base(a.Path),
+		Version: main.Version,
+		PURL:    main.purl(),
+		Hashes:  []cdxHash{{Alg: "SHA-256", Content: a.SHA256}},
+	}
+	for _, kv := range a.properties() {
+		mainComponent.Properties = append(mainComponent.Properties, cdxProperty{Name: toolName + ":" + kv[0], Value: kv[1]})
+	}
+
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + a.serial(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: a.Version.BuildDate,
+			Tools:     cdxTools{Components: []cdxComponent{{Type: "application", Name: toolName}}},
+			Component: mainComponent,
+		},
+		Components: []cdxComponent{},
+	}
+	mainDep := cdxDependency{Ref: main.purl()}
+	for _, d := range a.dependencies() {
+		c := cdxComponent{
+			Type:    "library",
+			BOMRef:  d.purl(),
+			Name:    d.Path,
+			Version: d.Version,
+			PURL:    d.purl(),
+		}
+		if d.GoSum != "" {
+			c.Properties = []cdxProperty{{Name: toolName + ":go.sum", Value: d.GoSum}}
+		}
+		bom.Components = append(bom.Components, c)
+		mainDep.DependsOn = append(mainDep.DependsOn, d.purl())
+	}
+	bom.Dependencies = []cdxDependency{mainDep}
+	return json.MarshalIndent(bom, "", "  ")
+}
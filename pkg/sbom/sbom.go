@@ -0,0 +1,193 @@
+package sbom
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/zoumo/make-rules/version"
+)
+
+const (
+	// CycloneDXExt is the file extension of CycloneDX SBOM
+	CycloneDXExt = ".cdx.json"
+	// SPDXExt is the file extension of SPDX SBOM
+	SPDXExt = ".spdx.json"
+
+	toolName = "make-rules"
+)
+
+// Artifact is a go binary described by SBOM
+type Artifact struct {
+	// Path is the path of binary
+	Path string
+	// SHA256 is the hex sha256 digest of binary
+	SHA256 string
+	// BuildInfo is the build info embedded in binary by go
+	BuildInfo *debug.BuildInfo
+	// Version is the version info of build
+	Version version.Info
+	// Sums are go.sum hashes keyed by "path version", they are used for
+	// modules without hash in build info
+	Sums map[string]string
+}
+
+// NewArtifact reads build info and digest of binary
+func NewArtifact(path string, info version.Info, sums map[string]string) (*Artifact, error) {
+	bi, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build info of %s: %w", path, err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return &Artifact{
+		Path:      path,
+		SHA256:    hex.EncodeToString(h.Sum(nil)),
+		BuildInfo: bi,
+		Version:   info,
+		Sums:      sums,
+	}, nil
+}
+
+// ReadGoSum reads module hashes in go.sum keyed by "path version", hashes
+// of go.mod files are ignored. An empty map is returned if file does not
+// exist.
+func ReadGoSum(file string) (map[string]string, error) {
+	sums := map[string]string{}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return sums, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		sums[fields[0]+" "+fields[1]] = fields[2]
+	}
+	return sums, s.Err()
+}
+
+// Write writes CycloneDX and SPDX SBOM of artifact next to it, and returns
+// their paths
+func Write(a *Artifact) ([]string, error) {
+	files := []string{}
+	for _, f := range []struct {
+		ext    string
+		encode func() ([]byte, error)
+	}{
+		{CycloneDXExt, a.CycloneDX},
+		{SPDXExt, a.SPDX},
+	} {
+		data, err := f.encode()
+		if err != nil {
+			return nil, err
+		}
+		file := a.Path + f.ext
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// module is a go module in SBOM
+type module struct {
+	Path    string
+	Version string
+	// GoSum is the go.sum hash of module, e.g. h1:<base64>, it is empty if
+	// unknown. It is a hash of the module file tree, not of any artifact, so
+	// it is not a checksum of the package.
+	GoSum string
+}
+
+// purl returns the package url of module
+func (m module) purl() string {
+	if m.Version == "" {
+		return "pkg:golang/" + m.Path
+	}
+	return "pkg:golang/" + m.Path + "@" + m.Version
+}
+
+// mainModule returns the main module, its version falls back to the git
+// version if go does not stamp it
+func (a *Artifact) mainModule() module {
+	m := module{Path: a.BuildInfo.Main.Path, Version: a.BuildInfo.Main.Version}
+	if m.Path == "" {
+		m.Path = a.BuildInfo.Path
+	}
+	if m.Version == "" || m.Version == "(devel)" {
+		m.Version = a.Version.GitVersion
+	}
+	return m
+}
+
+// dependencies returns dependency modules, replaced modules are described
+// by their replacements
+func (a *Artifact) dependencies() []module {
+	deps := []module{}
+	for _, d := range a.BuildInfo.Deps {
+		if d.Replace != nil {
+			d = d.Replace
+		}
+		sum := d.Sum
+		if sum == "" {
+			sum = a.Sums[d.Path+" "+d.Version]
+		}
+		deps = append(deps, module{Path: d.Path, Version: d.Version, GoSum: sum})
+	}
+	return deps
+}
+
+// properties returns build settings and version info as key value pairs
+func (a *Artifact) properties() [][2]string {
+	props := [][2]string{{"go.version", a.BuildInfo.GoVersion}}
+	for _, s := range a.BuildInfo.Settings {
+		props = append(props, [2]string{"go.build." + s.Key, s.Value})
+	}
+	v := a.Version
+	for _, kv := range [][2]string{
+		{"gitVersion", v.GitVersion},
+		{"gitCommit", v.GitCommit},
+		{"gitRemote", v.GitRemote},
+		{"gitTreeState", v.GitTreeState},
+		{"buildDate", v.BuildDate},
+		{"buildProfile", v.BuildProfile},
+		{"goVersion", v.GoVersion},
+		{"compiler", v.Compiler},
+		{"platform", v.Platform},
+	} {
+		if kv[1] != "" {
+			props = append(props, [2]string{"version." + kv[0], kv[1]})
+		}
+	}
+	return props
+}
+
+// serial returns a deterministic uuid derived from the binary digest, so
+// that SBOMs of reproducible builds are reproducible too
+func (a *Artifact) serial() string {
+	h := sha256.Sum256([]byte(a.SHA256))
+	h[6] = (h[6] & 0x0f) | 0x50 // version 5 style
+	h[8] = (h[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
@@ -0,0 +1,102 @@
+package sbom
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"testing"
+
+	"github.com/zoumo/make-rules/version"
+)
+
+func testArtifact() *Artifact {
+	return &Artifact{
+		Path:   "/bin/server",
+		SHA256: "abc",
+		BuildInfo: &debug.BuildInfo{
+			GoVersion: "go1.23.0",
+			Path:      "example.com/proj/cmd/server",
+			Main:      debug.Module{Path: "example.com/proj", Version: "(devel)"},
+			Deps: []*debug.Module{
+				{Path: "example.com/a", Version: "v1.0.0", Sum: "h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+				{Path: "example.com/b", Version: "v1.0.0", Replace: &debug.Module{Path: "example.com/c", Version: "v2.0.0"}},
+			},
+			Settings: []debug.BuildSetting{{Key: "GOOS", Value: "linux"}},
+		},
+		Version: version.Info{GitVersion: "v0.1.0", BuildDate: "2024-01-01T00:00:00Z"},
+		Sums:    map[string]string{"example.com/c v2.0.0": "h1://////////////////////////////////////////8="},
+	}
+}
+
+func TestReadGoSum(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "go.sum")
+	data := "example.com/a v1.0.0 h1:aaa=\nexample.com/a v1.0.0/go.mod h1:bbb=\n"
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadGoSum(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"example.com/a v1.0.0": "h1:aaa="}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadGoSum() = %v, want %v", got, want)
+	}
+	if got, err := ReadGoSum(filepath.Join(t.TempDir(), "go.sum")); err != nil || len(got) != 0 {
+		t.Errorf("ReadGoSum() of missing file = %v, %v", got, err)
+	}
+}
+
+func TestArtifact_dependencies(t *testing.T) {
+	got := testArtifact().dependencies()
+	want := []module{
+		{Path: "example.com/a", Version: "v1.0.0", GoSum: "h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+		{Path: "example.com/c", Version: "v2.0.0", GoSum: "h1://////////////////////////////////////////8="},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies() = %v, want %v", got, want)
+	}
+}
+
+func TestArtifact_CycloneDX(t *testing.T) {
+	data, err := testArtifact().CycloneDX()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom := cdxBOM{}
+	if err := json.Unmarshal(data, &bom); err != nil {
+		t.Fatal(err)
+	}
+	if bom.Metadata.Component.Version != "v0.1.0" {
+		t.Errorf("main version = %s, want v0.1.0", bom.Metadata.Component.Version)
+	}
+	if len(bom.Components) != 2 || bom.Components[1].PURL != "pkg:golang/example.com/c@v2.0.0" {
+		t.Errorf("unexpected components %+v", bom.Components)
+	}
+	if len(bom.Dependencies) != 1 || len(bom.Dependencies[0].DependsOn) != 2 {
+		t.Errorf("unexpected dependencies %+v", bom.Dependencies)
+	}
+	// go.sum hash is not a checksum of the module
+	if c := bom.Components[0]; len(c.Hashes) != 0 || len(c.Properties) != 1 || c.Properties[0].Value != "h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=" {
+		t.Errorf("unexpected hashes and properties of dependency %+v", c)
+	}
+}
+
+func TestArtifact_SPDX(t *testing.T) {
+	data, err := testArtifact().SPDX()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := spdxDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Packages) != 3 || doc.Packages[0].SPDXID != "SPDXRef-Package-example.com-proj-v0.1.0" {
+		t.Errorf("unexpected packages %+v", doc.Packages)
+	}
+	if len(doc.Relationships) != 3 || doc.Relationships[0].RelationshipType != "DESCRIBES" {
+		t.Errorf("unexpected relationships %+v", doc.Relationships)
+	}
+}
@@ -0,0 +1,142 @@
+package sbom
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+)
+
+// spdx types are the subset of SPDX 2.3 JSON used by make-rules
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	Annotations      []spdxAnnotation  `json:"annotations,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxAnnotation struct {
+	AnnotationType string `json:"annotationType"`
+	Annotator      string `json:"annotator"`
+	AnnotationDate string `json:"annotationDate"`
+	Comment        string `json:"comment"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+var spdxIDInvalidChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+func spdxID(m module) string {
+	return "SPDXRef-Package-" + spdxIDInvalidChars.ReplaceAllString(m.Path+"-"+m.Version, "-")
+}
+
+func spdxPurl(m module) []spdxExternalRef {
+	return []spdxExternalRef{{
+		ReferenceCategory: "PACKAGE-MANAGER",
+		ReferenceType:     "purl",
+		ReferenceLocator:  m.purl(),
+	}}
+}
+
+// SPDX returns the SPDX 2.3 JSON SBOM of artifact
+func (a *Artifact) SPDX() ([]byte, error) {
+	created := a.Version.BuildDate
+	if created == "" {
+		created = "1970-01-01T00:00:00Z"
+	}
+	name := filepath.Base(a.Path)
+	main := a.mainModule()
+	mainPkg := spdxPackage{
+		SPDXID:           spdxID(main),
+		Name:             main.Path,
+		VersionInfo:      main.Version,
+		DownloadLocation: "NOASSERTION",
+		Checksums:        []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: a.SHA256}},
+		ExternalRefs:     spdxPurl(main),
+	}
+	for _, kv := range a.properties() {
+		mainPkg.Annotations = append(mainPkg.Annotations, spdxAnnotation{
+			AnnotationType: "OTHER",
+			Annotator:      "Tool: " + toolName,
+			AnnotationDate: created,
+			Comment:        kv[0] + "=" + kv[1],
+		})
+	}
+
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: "https://spdx.org/spdxdocs/" + name + "-" + a.serial(),
+		CreationInfo: spdxCreationInfo{
+			Created:  created,
+			Creators: []string{"Tool: " + toolName},
+		},
+		Packages: []spdxPackage{mainPkg},
+		Relationships: []spdxRelationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: mainPkg.SPDXID,
+		}},
+	}
+	for _, d := range a.dependencies() {
+		pkg := spdxPackage{
+			SPDXID:           spdxID(d),
+			Name:             d.Path,
+			VersionInfo:      d.Version,
+			DownloadLocation: "NOASSERTION",
+			ExternalRefs:     spdxPurl(d),
+		}
+		if d.GoSum != "" {
+			pkg.Annotations = []spdxAnnotation{{
+				AnnotationType: "OTHER",
+				Annotator:      "Tool: " + toolName,
+				AnnotationDate: created,
+				Comment:        "go.sum=" + d.GoSum,
+			}}
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      mainPkg.SPDXID,
+			RelationshipType:   "DEPENDS_ON",
+			RelatedSPDXElement: pkg.SPDXID,
+		})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -23,5 +23,6 @@ var (
 	gitTreeState = ""            // state of git tree, either "clean" or "dirty"
 	gitRemote    = ""
 
-	buildDate = "1970-01-01T00:00:00Z" // build date in ISO8601 format, output of $(date -u +'%Y-%m-%dT%H:%M:%SZ')
+	buildDate    = "1970-01-01T00:00:00Z" // build date in ISO8601 format, output of $(date -u +'%Y-%m-%dT%H:%M:%SZ')
+	buildProfile = ""                     // name of go build profile, e.g. release
 )
@@ -21,6 +21,7 @@ type Info struct {
 	GitVersion   string `json:"gitVersion"`
 	GitTreeState string `json:"gitTreeState"`
 	BuildDate    string `json:"buildDate"`
+	BuildProfile string `json:"buildProfile,omitempty"`
 	GoVersion    string `json:"goVersion"`
 	Compiler     string `json:"compiler"`
 	Platform     string `json:"platform"`
@@ -64,6 +65,7 @@ func Get() Info {
 		GitTreeState: gitTreeState,
 		GitRemote:    gitRemote,
 		BuildDate:    buildDate,
+		BuildProfile: buildProfile,
 		GoVersion:    runtime.Version(),
 		Compiler:     runtime.Compiler,
 		Platform:     fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),